@@ -4,32 +4,86 @@
  * Drives Lightpanda via Puppeteer to verify:
  * - Login + dashboard loads
  * - Pagination (hasMore, Prev/Next buttons)
- * - @count badges
+ * - @count badges, with the Users badge matching the @count API
  * - PATCH partial update + rev
  * - Optimistic locking 409
+ * - Column sorting
+ * - Search/filter input
+ * - Pagination resets to page 1 when switching resources
+ * - Create dialog closes after a successful submit
+ * - "n" keyboard shortcut opens the create dialog
+ * - Update + delete entirely through the UI
+ * - Dashboard load time budget (each < 3s, median of 3 < 2s)
+ * - Records this suite seeds only use fields /meta/schema declares
+ * - The Users table has one header per visible /meta/schema field
+ * - No JS exceptions or console.error during navigation
+ * - Fresh data directory (own openerpd, needs OPENERPD_BIN and
+ *   OPENERP_BIN): zero @count badges stay empty, never "undefined"/"NaN",
+ *   and (Chromium only) the dashboard matches the screenshot baseline in
+ *   SCREENSHOT_BASELINE within 5% of pixels (a missing baseline fails);
+ *   UPDATE_BASELINE=1 writes it instead
+ * - Session expiry (own openerpd with jwt.expire_secs = 1 and
+ *   jwt.leeway_secs = 0, needs OPENERPD_BIN and OPENERP_BIN): once the
+ *   server refuses the logged-in token, the dashboard lands back on the
+ *   login form
+ * - Server outage mid-session (own openerpd, needs OPENERPD_BIN and
+ *   OPENERP_BIN): offline banner shows, and clears after a restart
+ * - Cancellation: aborting page.goto / waitForFunction through an
+ *   AbortSignal rejects within 2s instead of hanging, and the page
+ *   stays usable
+ * - CDP events: a raw CDP session on the page receives Page.loadEventFired
+ *   within 5s of navigating to the dashboard
+ * - Teardown after a failing action (own openerpd, needs OPENERPD_BIN and
+ *   OPENERP_BIN): in a child `node --test` run of fixtures/failing-action.mjs,
+ *   an error thrown mid-action fails the test, and the shared suite hooks
+ *   still close the page, release the browser and stop openerpd
+ * - With LIGHTPANDA_WS: no CDP page sessions are left open after a
+ *   connect/cleanup cycle (checked via the browser's /json list), and
+ *   each listed page has a webSocketDebuggerUrl on the LIGHTPANDA_WS host
+ *   that carries its target ID
  *
  * Usage:
- *   LIGHTPANDA_WS=ws://127.0.0.1:9222 BASE_URL=http://localhost:8088 \
- *   node --test dashboard.test.mjs
+ *   OPENERP_E2E_ENABLED=1 LIGHTPANDA_WS=ws://127.0.0.1:9222 \
+ *   BASE_URL=http://localhost:8088 node --test dashboard.test.mjs
+ *
+ * WS_URL is accepted in place of LIGHTPANDA_WS, matching the browser
+ * runner. Without either a local Chromium is launched instead. Without
+ * OPENERP_E2E_ENABLED=1 (set by the browser runner) the suite is skipped,
+ * so a stray `node --test` in CI passes instead of failing.
  */
 
-import { describe, it, before, after } from 'node:test';
+import { describe, it, before, after, beforeEach } from 'node:test';
 import assert from 'node:assert/strict';
-import puppeteer from 'puppeteer';
+import { spawnSync } from 'node:child_process';
+import { existsSync, mkdirSync, mkdtempSync, readFileSync, rmSync, writeFileSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { dirname, join } from 'node:path';
+import { fileURLToPath } from 'node:url';
+import { startServer } from '../shared/openerpd.mjs';
+import { decodePng, pixelDiff } from './png.mjs';
+import { LIGHTPANDA_WS, closeBrowser, openBrowser, ownServerSession } from './session.mjs';
 
 const BASE_URL = process.env.BASE_URL || 'http://localhost:8088';
 const ROOT_USER = 'root';
 const ROOT_PASS = process.env.ROOT_PASS || 'openerp123';
+const E2E_ENABLED = process.env.OPENERP_E2E_ENABLED === '1';
+const OPENERPD_BIN = process.env.OPENERPD_BIN;
+const OPENERP_BIN = process.env.OPENERP_BIN;
+// Golden dashboard screenshot; the runner points this into testdata/.
+const SCREENSHOT_BASELINE = process.env.SCREENSHOT_BASELINE;
+const UPDATE_BASELINE = process.env.UPDATE_BASELINE === '1';
+// Child suite for the teardown test.
+const FAILING_ACTION = fileURLToPath(new URL('./fixtures/failing-action.mjs', import.meta.url));
 
 /** Make an API call directly (bypassing browser). */
-async function api(method, path, body, token) {
+async function api(method, path, body, token, baseUrl = BASE_URL) {
   const opts = {
     method,
     headers: { 'Content-Type': 'application/json' },
   };
   if (token) opts.headers['Authorization'] = `Bearer ${token}`;
   if (body) opts.body = JSON.stringify(body);
-  const resp = await fetch(`${BASE_URL}${path}`, opts);
+  const resp = await fetch(`${baseUrl}${path}`, opts);
   const text = await resp.text();
   let data = null;
   if (text) { try { data = JSON.parse(text); } catch(e) {} }
@@ -37,26 +91,60 @@ async function api(method, path, body, token) {
 }
 
 /** Login via API, return JWT token. */
-async function getToken() {
+async function getToken(baseUrl = BASE_URL) {
   const { data } = await api('POST', '/auth/login', {
     username: ROOT_USER,
     password: ROOT_PASS,
-  });
+  }, null, baseUrl);
   return data?.access_token || data?.token;
 }
 
-describe('Dashboard DSL Polish (Lightpanda)', () => {
+/** Click the first sidebar item whose text matches `pattern` (a regex source). */
+async function openResource(page, pattern) {
+  await page.evaluate((src) => {
+    const re = new RegExp(src, 'i');
+    const items = document.querySelectorAll('.sidebar .nav-item');
+    for (const i of items) { if (re.test(i.textContent)) { i.click(); break; } }
+  }, pattern);
+  await new Promise(r => setTimeout(r, 1000));
+}
+
+/** Read the text of column `header` (regex source) for every table row. */
+async function columnValues(page, header) {
+  return page.evaluate((src) => {
+    const re = new RegExp(src, 'i');
+    const ths = [...document.querySelectorAll('.table-card table th')];
+    const idx = ths.findIndex(th => re.test(th.textContent));
+    if (idx < 0) return null;
+    return [...document.querySelectorAll('#resBody tr')]
+      .map(tr => tr.children[idx]?.textContent.trim() ?? '');
+  }, header);
+}
+
+/** Page targets the CDP browser at LIGHTPANDA_WS currently has open. */
+async function cdpPages() {
+  const url = new URL(LIGHTPANDA_WS);
+  url.protocol = url.protocol === 'wss:' ? 'https:' : 'http:';
+  url.pathname = '/json';
+  const resp = await fetch(url);
+  assert.equal(resp.status, 200, `GET ${url}`);
+  const targets = await resp.json();
+  return targets.filter(t => t.type === 'page');
+}
+
+const skip = E2E_ENABLED ? false : 'OPENERP_E2E_ENABLED=1 not set';
+
+describe('Dashboard DSL Polish (Lightpanda)', { skip }, () => {
   let browser;
   let context;
   let page;
   let token;
+  const jsErrors = [];
+  // Test that is running, so JS errors say which step raised them.
+  let step = 'setup';
 
   before(async () => {
-    // Launch Chromium (puppeteer auto-downloads it).
-    browser = await puppeteer.launch({
-      headless: true,
-      args: ['--no-sandbox', '--disable-setuid-sandbox', '--disable-dev-shm-usage'],
-    });
+    browser = await openBrowser();
 
     // Get API token.
     token = await getToken();
@@ -64,6 +152,12 @@ describe('Dashboard DSL Polish (Lightpanda)', () => {
 
     page = await browser.newPage();
 
+    // Collect JS errors from the very first navigation on.
+    page.on('pageerror', (err) => jsErrors.push(`[${step}] exception: ${err.message}`));
+    page.on('console', (msg) => {
+      if (msg.type() === 'error') jsErrors.push(`[${step}] console.error: ${msg.text()}`);
+    });
+
     // Navigate to login page, inject token, then go to dashboard.
     await page.goto(`${BASE_URL}/`, { waitUntil: 'networkidle0' });
     await page.evaluate((t) => {
@@ -78,6 +172,10 @@ describe('Dashboard DSL Polish (Lightpanda)', () => {
     );
   });
 
+  beforeEach((t) => {
+    step = t.name;
+  });
+
   after(async () => {
     // Clean up test records.
     if (token) {
@@ -89,8 +187,14 @@ describe('Dashboard DSL Polish (Lightpanda)', () => {
           }
         }
       }
+      const groups = await api('GET', '/admin/auth/groups', null, token);
+      for (const g of groups.data?.items ?? []) {
+        if (g.displayName?.startsWith('E2E LP')) {
+          await api('DELETE', `/admin/auth/groups/${g.id}`, null, token);
+        }
+      }
     }
-    if (browser) await browser.close();
+    await closeBrowser(browser, page);
   });
 
   // ── 1. Dashboard loads ──
@@ -109,6 +213,23 @@ describe('Dashboard DSL Polish (Lightpanda)', () => {
     assert.ok(badges.length > 0, 'Expected sidebar count badges');
   });
 
+  it('Users badge matches the @count API', async () => {
+    await openResource(page, 'user');
+    // Badges are filled asynchronously; an empty or hidden badge means 0.
+    const shown = await page.evaluate(() => {
+      const items = [...document.querySelectorAll('.sidebar .nav-item')];
+      const users = items.find(i => /user/i.test(i.textContent));
+      return users?.querySelector('.sidebar-count')?.textContent.trim() ?? null;
+    });
+    assert.notEqual(shown, null, 'Users nav item has a count badge');
+
+    const { status, data } = await api('GET', '/admin/auth/users/@count', null, token);
+    assert.equal(status, 200);
+    const diff = Math.abs(Number(shown || 0) - data.count);
+    // Allow one create landing between the two reads.
+    assert.ok(diff <= 1, `badge shows ${shown || 0}, @count is ${data.count}`);
+  });
+
   // ── 3. Pagination UI ──
 
   it('has pagination controls', async () => {
@@ -144,33 +265,39 @@ describe('Dashboard DSL Polish (Lightpanda)', () => {
   });
 
   // ── 6. Create record via API (dialog CRUD tested in 02-dashboard-crud) ──
+  //
+  // Tests 6-9 each create their own record (cleaned up in `after` by the
+  // 'E2E LP' prefix), so none depends on another having run first.
 
-  let createdId;
-
-  it('creates a record via API', async () => {
+  /** Create a user named `E2E LP <suffix>` via the API; returns the record. */
+  async function createUser(suffix) {
     const { status, data } = await api('POST', '/admin/auth/users', {
-      displayName: 'E2E LP Dashboard',
+      displayName: `E2E LP ${suffix}`,
       active: true,
     }, token);
     assert.equal(status, 200, 'create should succeed');
-    assert.ok(data.id, 'should have id');
-    createdId = data.id;
+    return data;
+  }
+
+  it('creates a record via API', async () => {
+    const created = await createUser('Dashboard');
+    assert.ok(created.id, 'should have id');
   });
 
   // ── 7. rev=1 on create ──
 
   it('created record has rev=1', async () => {
-    assert.ok(createdId, 'need created record');
-    const { data } = await api('GET', `/admin/auth/users/${createdId}`, null, token);
+    const { id } = await createUser('Rev');
+    const { data } = await api('GET', `/admin/auth/users/${id}`, null, token);
     assert.equal(data.rev, 1, 'rev should be 1 after create');
   });
 
   // ── 8. PATCH partial update ──
 
   it('PATCH updates only changed fields', async () => {
-    assert.ok(createdId, 'need created record');
+    const { id } = await createUser('Patch');
     const patch = { displayName: 'E2E LP Patched', rev: 1 };
-    const { status, data } = await api('PATCH', `/admin/auth/users/${createdId}`, patch, token);
+    const { status, data } = await api('PATCH', `/admin/auth/users/${id}`, patch, token);
     assert.equal(status, 200, 'PATCH should succeed');
     assert.equal(data.displayName, 'E2E LP Patched');
     assert.equal(data.rev, 2, 'rev should be bumped to 2');
@@ -179,9 +306,11 @@ describe('Dashboard DSL Polish (Lightpanda)', () => {
   // ── 9. Stale rev → 409 ──
 
   it('stale rev returns 409 Conflict', async () => {
-    assert.ok(createdId, 'need created record');
+    const { id } = await createUser('Stale');
+    const first = await api('PATCH', `/admin/auth/users/${id}`, { displayName: 'E2E LP Stale 2', rev: 1 }, token);
+    assert.equal(first.status, 200, 'first PATCH should succeed');
     const patch = { displayName: 'Should Fail', rev: 1 };
-    const { status } = await api('PATCH', `/admin/auth/users/${createdId}`, patch, token);
+    const { status } = await api('PATCH', `/admin/auth/users/${id}`, patch, token);
     assert.equal(status, 409, 'stale rev should return 409');
   });
 
@@ -198,4 +327,675 @@ describe('Dashboard DSL Polish (Lightpanda)', () => {
     const badge = await page.$('#countBadge');
     assert.ok(badge, 'Count badge should exist in section header');
   });
+
+  // ── 11. Column sorting ──
+
+  it('sorts the table by clicking a column header', async () => {
+    for (const name of ['E2E LP Sort Charlie', 'E2E LP Sort Alpha', 'E2E LP Sort Bravo']) {
+      const { status } = await api('POST', '/admin/auth/users', {
+        displayName: name, active: true,
+      }, token);
+      assert.equal(status, 200, `create ${name}`);
+    }
+    await openResource(page, 'user');
+
+    const clickHeader = () => page.evaluate(() => {
+      const th = [...document.querySelectorAll('.table-card table th')]
+        .find(el => /display/i.test(el.textContent));
+      if (th) th.click();
+      return !!th;
+    });
+    const sortRows = async () => {
+      await new Promise(r => setTimeout(r, 500));
+      const values = await columnValues(page, 'display');
+      return values.filter(v => v.startsWith('E2E LP Sort'));
+    };
+
+    assert.ok(await clickHeader(), 'displayName column header exists');
+    const asc = await sortRows();
+    assert.equal(asc.length, 3, `Expected 3 sort rows, got: ${asc}`);
+    assert.deepEqual(asc, [...asc].sort(), 'First click sorts ascending');
+    assert.equal(asc[0], 'E2E LP Sort Alpha');
+
+    await clickHeader();
+    const desc = await sortRows();
+    assert.deepEqual(desc, [...asc].reverse(), 'Second click reverses the order');
+  });
+
+  // ── 12. Search/filter input ──
+
+  it('search input narrows the displayed records', async () => {
+    const { status } = await api('POST', '/admin/auth/users', {
+      displayName: 'E2E LP FilterTarget', active: true,
+    }, token);
+    assert.equal(status, 200);
+    await openResource(page, 'user');
+
+    const rowTexts = () => page.$$eval('#resBody tr', rows => rows.map(r => r.textContent));
+    const all = await rowTexts();
+    assert.ok(all.length > 1, `Need several rows to filter, got ${all.length}`);
+
+    const input = await page.$('#searchInput');
+    assert.ok(input, 'Search input exists');
+    await input.type('FilterTarget');
+    await new Promise(r => setTimeout(r, 500));
+
+    const filtered = await rowTexts();
+    assert.ok(filtered.length >= 1, 'Filter keeps the matching row');
+    assert.ok(
+      filtered.every(t => t.includes('FilterTarget')),
+      `Every row should match, got: ${filtered}`,
+    );
+
+    // Clear the input — all records come back.
+    await input.click({ clickCount: 3 });
+    await page.keyboard.press('Backspace');
+    await new Promise(r => setTimeout(r, 500));
+    const cleared = await rowTexts();
+    assert.equal(cleared.length, all.length, 'All rows reappear after clearing');
+  });
+
+  // ── 13. Pagination state across resources ──
+
+  // Documented behaviour: each resource opens on page 1; pagination state
+  // is not carried over when switching away and back.
+  it('resets pagination to page 1 when switching resources', async () => {
+    for (let i = 0; i < 3; i++) {
+      const u = await api('POST', '/admin/auth/users', {
+        displayName: `E2E LP Nav User ${i}`, active: true,
+      }, token);
+      assert.equal(u.status, 200);
+      const g = await api('POST', '/admin/auth/groups', {
+        displayName: `E2E LP Nav Group ${i}`,
+      }, token);
+      assert.equal(g.status, 200);
+    }
+
+    const state = () => page.evaluate(() => ({
+      prevDisabled: document.querySelector('#prevBtn')?.disabled,
+      nextDisabled: document.querySelector('#nextBtn')?.disabled,
+      firstRow: document.querySelector('#resBody tr')?.textContent ?? '',
+    }));
+
+    await openResource(page, 'user');
+    const usersPage1 = await state();
+    assert.equal(usersPage1.prevDisabled, true, 'Users opens on page 1');
+
+    // Move off page 1 when there is more than one page.
+    if (!usersPage1.nextDisabled) {
+      await page.click('#nextBtn');
+      await new Promise(r => setTimeout(r, 1000));
+      assert.equal((await state()).prevDisabled, false, 'Users moved to page 2');
+    }
+
+    await openResource(page, 'group');
+    const groups = await state();
+    assert.equal(groups.prevDisabled, true, 'Groups opens on page 1');
+    assert.ok(groups.firstRow !== usersPage1.firstRow, 'Groups shows its own rows');
+
+    await openResource(page, 'user');
+    const usersAgain = await state();
+    assert.equal(usersAgain.prevDisabled, true, 'Users is back on page 1');
+    assert.equal(usersAgain.firstRow, usersPage1.firstRow, 'Same first row as before');
+  });
+
+  // ── 14. Create dialog closes after submit ──
+
+  it('closes the create dialog after a successful submit', async () => {
+    await openResource(page, 'user');
+    await page.evaluate(() => document.querySelector('.btn-sm-primary')?.click());
+    await page.waitForFunction(
+      () => document.getElementById('createDlg')?.classList.contains('open'),
+      { timeout: 3000 },
+    );
+
+    await page.type('#dlgForm input[name="display_name"]', 'E2E LP Dialog Create');
+    await page.click('#dlgSubmit');
+    await new Promise(r => setTimeout(r, 1000));
+
+    const open = await page.$eval('#createDlg', el => el.classList.contains('open'));
+    assert.equal(open, false, 'dialog dismissed after submit');
+
+    const { data } = await api('GET', '/admin/auth/users', null, token);
+    assert.ok(
+      (data.items ?? []).some(u => u.displayName === 'E2E LP Dialog Create'),
+      'record created',
+    );
+  });
+
+  // ── 15. Keyboard shortcut opens the create dialog ──
+
+  it('pressing n opens the create dialog', async () => {
+    await openResource(page, 'user');
+    // Make sure no input has focus; the shortcut is ignored while typing.
+    await page.evaluate(() => document.activeElement?.blur());
+    await page.keyboard.press('n');
+    await page.waitForFunction(
+      () => document.getElementById('createDlg')?.classList.contains('open'),
+      { timeout: 1000 },
+    );
+
+    await page.keyboard.press('Escape');
+    const open = await page.$eval('#createDlg', el => el.classList.contains('open'));
+    assert.equal(open, false, 'Escape closes the dialog again');
+  });
+
+  // ── 16. Update + delete through the UI ──
+
+  it('updates and deletes a record through the UI', async () => {
+    const created = await api('POST', '/admin/auth/users', {
+      displayName: 'E2E LP UI Crud', active: true,
+    }, token);
+    assert.equal(created.status, 200);
+    const id = created.data.id;
+
+    await openResource(page, 'user');
+    const openRow = async (name) => {
+      const found = await page.evaluate((n) => {
+        const row = [...document.querySelectorAll('#resBody tr')]
+          .find(r => r.textContent.includes(n));
+        if (row) row.click();
+        return !!row;
+      }, name);
+      assert.ok(found, `row '${name}' visible`);
+      await page.waitForFunction(
+        () => document.getElementById('createDlg')?.classList.contains('open'),
+        { timeout: 3000 },
+      );
+    };
+
+    // Update: edit dialog → change display name → Save.
+    await openRow('E2E LP UI Crud');
+    await page.$eval('#dlgForm input[name="display_name"]', el => { el.value = ''; });
+    await page.type('#dlgForm input[name="display_name"]', 'E2E LP UI Edited');
+    await page.click('#dlgSubmit');
+    await page.waitForFunction(
+      () => !document.getElementById('createDlg')?.classList.contains('open'),
+      { timeout: 5000 },
+    );
+    const updated = await api('GET', `/admin/auth/users/${id}`, null, token);
+    assert.equal(updated.data.displayName, 'E2E LP UI Edited');
+
+    // Delete: the row's delete button, or the one in the edit dialog;
+    // accept the confirm() prompt.
+    await page.waitForFunction(
+      () => document.getElementById('resBody')?.textContent.includes('E2E LP UI Edited'),
+      { timeout: 5000 },
+    );
+    const onDialog = (d) => d.accept();
+    page.on('dialog', onDialog);
+    try {
+      // `rowText` set: search that table row; otherwise the open dialog.
+      const clickDelete = (rowText) => page.evaluate((text) => {
+        const isDel = (b) => /delete|remove|删除/i.test(`${b.textContent} ${b.title}`);
+        const scope = text
+          ? [...document.querySelectorAll('#resBody tr')].find(r => r.textContent.includes(text))
+          : document.getElementById('createDlg');
+        const btn = scope && [...scope.querySelectorAll('button')].find(isDel);
+        if (btn) btn.click();
+        return !!btn;
+      }, rowText ?? null);
+
+      let clicked = await clickDelete('E2E LP UI Edited');
+      if (!clicked) {
+        await openRow('E2E LP UI Edited');
+        clicked = await clickDelete();
+      }
+      assert.ok(clicked, 'Delete button exists');
+      await page.waitForFunction(
+        () => !document.getElementById('resBody')?.textContent.includes('E2E LP UI Edited'),
+        { timeout: 5000 },
+      );
+    } finally {
+      page.off('dialog', onDialog);
+    }
+
+    const gone = await api('GET', `/admin/auth/users/${id}`, null, token);
+    assert.equal(gone.status, 404, 'Record deleted');
+  });
+
+  // ── 17. Load time budget ──
+
+  it('loads the dashboard within the time budget', async () => {
+    const times = [];
+    for (let i = 0; i < 3; i++) {
+      const start = performance.now();
+      await page.goto(`${BASE_URL}/dashboard`);
+      await page.waitForSelector('.sidebar .nav-item', { timeout: 5000 });
+      times.push(performance.now() - start);
+    }
+    for (const t of times) {
+      assert.ok(t < 3000, `Load took ${t.toFixed(0)}ms (budget 3000ms): ${times}`);
+    }
+    const median = [...times].sort((a, b) => a - b)[1];
+    assert.ok(median < 2000, `Median load ${median.toFixed(0)}ms (budget 2000ms)`);
+  });
+
+  // ── 18. Seed payloads match the schema ──
+  //
+  // Catches seed data drifting from the Rust DSL models: a renamed or
+  // removed field would otherwise be silently ignored by the server.
+  it('seed payloads only use fields the schema declares', async () => {
+    const { status, data: schema } = await api('GET', '/meta/schema');
+    assert.equal(status, 200);
+    const fields = (module, resource) => {
+      const ir = schema.modules.find(m => m.id === module)
+        ?.resources.find(r => r.resource === resource);
+      assert.ok(ir, `${module}/${resource} in schema`);
+      return new Set(ir.fields.map(f => f.name));
+    };
+    // Same shapes as the POST bodies above; schema names are snake_case.
+    const seeds = [
+      ['auth', 'user', { displayName: '', active: true }],
+      ['auth', 'group', { displayName: '' }],
+    ];
+    for (const [module, resource, body] of seeds) {
+      const known = fields(module, resource);
+      for (const key of Object.keys(body)) {
+        const name = key.replace(/[A-Z]/g, c => `_${c.toLowerCase()}`);
+        assert.ok(known.has(name), `${module}/${resource} has no field ${name}`);
+      }
+    }
+  });
+
+  // ── 19. Table columns match the schema ──
+  //
+  // Mirrors the dashboard's column rule: ID, Name (for display_name), up
+  // to five other visible fields, then the row actions column. A field
+  // added to or dropped from the model changes the expected header.
+  it('users table has one header per visible schema field', async () => {
+    const { data: schema } = await api('GET', '/meta/schema');
+    const ir = schema.modules.find(m => m.id === 'auth')
+      ?.resources.find(r => r.resource === 'user');
+    assert.ok(ir, 'auth/user in schema');
+    const pk = ir.key?.fields?.[0] || 'id';
+    const hasName = ir.fields.some(f => f.name === 'display_name');
+    const main = ir.fields.filter(f => {
+      const w = (f.widget || 'text').toLowerCase();
+      return w !== 'hidden' && w !== 'password'
+        && ![pk, 'display_name', 'description'].includes(f.name);
+    }).slice(0, 5);
+    const label = (name) => name.replace(/_/g, ' ').replace(/\b\w/g, c => c.toUpperCase());
+    const expected = ['ID', ...(hasName ? ['Name'] : []), ...main.map(f => label(f.name)), ''];
+
+    await openResource(page, 'user');
+    await page.waitForSelector('#resHead th', { timeout: 5000 });
+    const headers = await page.$$eval('#resHead th', els => els.map(e => e.textContent.trim()));
+    assert.equal(headers.length, expected.length, `headers: ${JSON.stringify(headers)}`);
+    assert.deepEqual(headers, expected);
+    assert.ok(!headers.some(h => /password/i.test(h)), 'password hash is never a column');
+  });
+
+  // ── 20. No JS errors (must stay last) ──
+
+  it('raised no JS exceptions or console errors', () => {
+    assert.deepEqual(jsErrors, [], `JS errors during navigation:\n${jsErrors.join('\n')}`);
+  });
+});
+
+describe('Fresh data directory', {
+  skip: skip || (OPENERPD_BIN && OPENERP_BIN ? false : 'OPENERPD_BIN / OPENERP_BIN not set'),
+}, () => {
+  // Context created, no records yet: every @count starts at 0.
+  const session = ownServerSession('fresh');
+  let baseUrl;
+  let token;
+  let page;
+  let pageErrors;
+
+  before(async () => {
+    ({ page, pageErrors } = session);
+    ({ baseUrl } = session.server);
+    token = await getToken(baseUrl);
+    assert.ok(token, 'should get JWT token');
+    await page.goto(`${baseUrl}/`, { waitUntil: 'networkidle0' });
+    await page.evaluate((t) => localStorage.setItem('openerp_token', t), token);
+    await page.goto(`${baseUrl}/dashboard`, { waitUntil: 'networkidle0' });
+    await page.waitForFunction(
+      () => document.querySelectorAll('.sidebar .nav-item').length > 0,
+      { timeout: 10000 },
+    );
+  });
+
+  it('renders zero counts as empty badges, never undefined or NaN', async () => {
+    // Badges are filled asynchronously after the sidebar renders.
+    await new Promise(r => setTimeout(r, 1000));
+    const badges = await page.$$eval('.sidebar-count', els => els.map(el => ({
+      resource: el.dataset.resource,
+      text: el.textContent.trim(),
+      shown: getComputedStyle(el).display !== 'none',
+    })));
+    assert.ok(badges.length > 0, 'Expected sidebar count badges');
+
+    for (const b of badges) {
+      assert.match(b.text, /^\d*$/, `${b.resource} badge shows "${b.text}"`);
+      assert.equal(b.shown, Number(b.text) > 0, `${b.resource}: "${b.text}" shown=${b.shown}`);
+    }
+
+    // Nothing created yet, so at least one resource must be at zero.
+    const { data } = await api('GET', '/admin/auth/groups/@count', null, token, baseUrl);
+    assert.equal(data.count, 0, 'fresh data dir has no groups');
+    const groups = badges.find(b => /group/i.test(b.resource));
+    assert.ok(groups, 'Groups nav item has a count badge');
+    assert.ok(groups.text === '' || groups.text === '0', `Groups badge shows "${groups.text}"`);
+    assert.deepEqual(pageErrors, [], `unhandled JS exceptions:\n${pageErrors.join('\n')}`);
+  });
+
+  // Here rather than in the main suite: an empty server renders the same
+  // dashboard on every run.
+  it('matches the dashboard screenshot baseline', async (t) => {
+    if (LIGHTPANDA_WS) return t.skip('Lightpanda does not render screenshots');
+    if (!SCREENSHOT_BASELINE) return t.skip('SCREENSHOT_BASELINE not set');
+
+    await page.setViewport({ width: 1280, height: 800 });
+    await page.reload({ waitUntil: 'networkidle0' });
+    await page.waitForFunction(
+      () => document.querySelectorAll('.sidebar .nav-item').length > 0,
+      { timeout: 10000 },
+    );
+    // Let the async @count badges settle.
+    await new Promise(r => setTimeout(r, 1000));
+    const shot = Buffer.from(await page.screenshot({ type: 'png' }));
+
+    if (UPDATE_BASELINE) {
+      mkdirSync(dirname(SCREENSHOT_BASELINE), { recursive: true });
+      writeFileSync(SCREENSHOT_BASELINE, shot);
+      t.diagnostic(`wrote baseline ${SCREENSHOT_BASELINE}`);
+      return;
+    }
+    // A missing baseline is a failure, not a silent first-run pass.
+    assert.ok(existsSync(SCREENSHOT_BASELINE), `no screenshot baseline at ${SCREENSHOT_BASELINE}; ` +
+      'create it by rerunning the runner with --update-baseline, then commit it');
+    const diff = pixelDiff(decodePng(readFileSync(SCREENSHOT_BASELINE)), decodePng(shot));
+    if (diff > 0.05) {
+      const actual = join(mkdtempSync(join(tmpdir(), 'openerp-shot-')), 'dashboard.actual.png');
+      writeFileSync(actual, shot);
+      assert.fail(`dashboard differs from baseline in ${(diff * 100).toFixed(1)}% of pixels ` +
+        `(limit 5%); actual screenshot: ${actual}. Rerun the runner with ` +
+        '--update-baseline if the change is intended');
+    }
+  });
+});
+
+describe('Session expiry', {
+  skip: skip || (OPENERPD_BIN && OPENERP_BIN ? false : 'OPENERPD_BIN / OPENERP_BIN not set'),
+}, () => {
+  // Logins on this server hand out tokens that expire after a second, and
+  // the server allows no clock skew past that.
+  const session = ownServerSession('expiry', {
+    configure(configPath) {
+      const config = readFileSync(configPath, 'utf8');
+      assert.match(config, /^expire_secs\s*=/m, `no [jwt] expire_secs in ${configPath}`);
+      writeFileSync(configPath,
+        config.replace(/^expire_secs\s*=.*$/m, 'expire_secs = 1\nleeway_secs = 0'));
+    },
+  });
+
+  it('sends an expired session back to the login form', async () => {
+    const { page, pageErrors } = session;
+    const { baseUrl } = session.server;
+    const token = await getToken(baseUrl);
+    assert.ok(token, 'should get JWT token');
+    await page.goto(`${baseUrl}/`, { waitUntil: 'networkidle0' });
+    await page.evaluate((t) => localStorage.setItem('openerp_token', t), token);
+    await page.goto(`${baseUrl}/dashboard`, { waitUntil: 'networkidle0' });
+    await page.waitForFunction(
+      () => document.querySelectorAll('.sidebar .nav-item').length > 0,
+      { timeout: 10000 },
+    );
+    assert.equal(new URL(page.url()).pathname, '/dashboard', 'token should still be valid');
+
+    // exp has one-second resolution; wait until the server refuses the
+    // token rather than guessing exactly when that happens.
+    const deadline = Date.now() + 10_000;
+    while ((await api('GET', '/admin/auth/users/@count', null, token, baseUrl)).status !== 401) {
+      assert.ok(Date.now() < deadline, 'token still accepted 10s after login');
+      await new Promise(r => setTimeout(r, 1000));
+    }
+
+    await openResource(page, 'user');
+    await page.waitForFunction(() => location.pathname === '/', { timeout: 10000 });
+    await page.waitForSelector('#loginForm', { timeout: 5000 });
+
+    const state = await page.evaluate(() => ({
+      token: localStorage.getItem('openerp_token'),
+      text: document.body.innerText,
+    }));
+    assert.equal(state.token, null, 'expired token should be cleared');
+    assert.ok(state.text.trim().length > 0, 'login page should not be blank');
+    assert.doesNotMatch(state.text, /"code"\s*:/, 'raw error JSON leaked onto the page');
+    assert.deepEqual(pageErrors, [], `unhandled JS exceptions:\n${pageErrors.join('\n')}`);
+  });
+});
+
+describe('Server outage mid-session', {
+  skip: skip || (OPENERPD_BIN && OPENERP_BIN ? false : 'OPENERPD_BIN / OPENERP_BIN not set'),
+}, () => {
+  // A server of our own, so stopping it doesn't break the suite above.
+  const session = ownServerSession('outage');
+  let page;
+  let pageErrors;
+
+  before(async () => {
+    ({ page, pageErrors } = session);
+    const { baseUrl } = session.server;
+    const token = await getToken(baseUrl);
+    assert.ok(token, 'should get JWT token');
+    await page.goto(`${baseUrl}/`, { waitUntil: 'networkidle0' });
+    await page.evaluate((t) => localStorage.setItem('openerp_token', t), token);
+    await page.goto(`${baseUrl}/dashboard`, { waitUntil: 'networkidle0' });
+    await page.waitForFunction(
+      () => document.querySelectorAll('.sidebar .nav-item').length > 0,
+      { timeout: 10000 },
+    );
+  });
+
+  it('shows an offline banner while the server is down and clears it after restart', async () => {
+    const bannerShown = () => document.getElementById('offlineBanner')?.classList.contains('show');
+    assert.equal(await page.evaluate(bannerShown), false, 'no banner while the server is up');
+
+    const { baseUrl } = session.server;
+    await session.server.stop();
+    await openResource(page, 'user');
+    await page.waitForFunction(bannerShown, { timeout: 5000 });
+    const content = await page.$eval('#content', el => el.textContent.trim());
+    assert.ok(content, 'resource page still rendered');
+
+    // The banner polls /health every 2s. Restart on the same port: the
+    // page's origin and token must stay valid.
+    session.server = await startServer(session.configPath, { port: Number(new URL(baseUrl).port) });
+    await page.waitForFunction(() => !document.getElementById('offlineBanner').classList.contains('show'),
+      { timeout: 10000 });
+    await page.waitForFunction(() => !document.getElementById('resBody')?.textContent.includes('Loading'),
+      { timeout: 5000 });
+
+    assert.deepEqual(pageErrors, [], `unhandled JS exceptions:\n${pageErrors.join('\n')}`);
+  });
+});
+
+describe('Cancellation', { skip }, () => {
+  let browser;
+  let page;
+
+  before(async () => {
+    browser = await openBrowser();
+    page = await browser.newPage();
+  });
+
+  after(async () => {
+    await closeBrowser(browser, page);
+  });
+
+  /** Settle `promise` within `ms`: its rejection, or a "hung" failure. */
+  async function rejectionWithin(promise, ms) {
+    let timer;
+    const hung = new Promise((resolve) => {
+      timer = setTimeout(() => resolve(new Error(`still pending after ${ms}ms`)), ms);
+    });
+    try {
+      return await Promise.race([
+        promise.then(() => new Error('completed despite the abort'), (err) => err),
+        hung,
+      ]);
+    } finally {
+      clearTimeout(timer);
+    }
+  }
+
+  it('aborting a navigation or wait rejects promptly instead of hanging', async () => {
+    const reason = new Error('cancelled by test');
+    const isAbort = (err) => err === reason || err?.name === 'AbortError';
+
+    // Aborted right after it starts, before the page can load.
+    const nav = new AbortController();
+    const navigating = page.goto(`${BASE_URL}/dashboard`, {
+      waitUntil: 'networkidle0', signal: nav.signal,
+    });
+    nav.abort(reason);
+    const navErr = await rejectionWithin(navigating, 2000);
+    assert.ok(isAbort(navErr), `goto: ${navErr}`);
+
+    // Aborted mid-wait on a condition that never becomes true.
+    const wait = new AbortController();
+    const waiting = page.waitForFunction(() => false, { timeout: 0, signal: wait.signal });
+    setTimeout(() => wait.abort(reason), 100);
+    const waitErr = await rejectionWithin(waiting, 2000);
+    assert.ok(isAbort(waitErr), `waitForFunction: ${waitErr}`);
+
+    // The page is still usable afterwards.
+    const resp = await page.goto(`${BASE_URL}/health`, { timeout: 10000 });
+    assert.equal(resp.status(), 200, 'navigation works after the aborted calls');
+  });
+});
+
+describe('CDP events', { skip }, () => {
+  let browser;
+  let page;
+
+  before(async () => {
+    browser = await openBrowser();
+    page = await browser.newPage();
+  });
+
+  after(async () => {
+    await closeBrowser(browser, page);
+  });
+
+  it('delivers Page.loadEventFired for a dashboard navigation', async () => {
+    // Below puppeteer's own load handling: the raw event, on a session of ours.
+    const session = await page.createCDPSession();
+    try {
+      await session.send('Page.enable');
+      let timer;
+      const fired = new Promise((resolve, reject) => {
+        timer = setTimeout(() => reject(new Error('no Page.loadEventFired within 5s')), 5000);
+        session.once('Page.loadEventFired', resolve);
+      });
+      try {
+        await Promise.all([fired, page.goto(`${BASE_URL}/dashboard`, { timeout: 5000 })]);
+      } finally {
+        clearTimeout(timer);
+      }
+      const event = await fired;
+      assert.equal(typeof event.timestamp, 'number', `event: ${JSON.stringify(event)}`);
+    } finally {
+      await session.detach();
+    }
+  });
+});
+
+describe('Teardown after a failing action', {
+  skip: skip || (OPENERPD_BIN && OPENERP_BIN ? false : 'OPENERPD_BIN / OPENERP_BIN not set'),
+}, () => {
+  let tmp;
+
+  after(() => {
+    if (tmp) rmSync(tmp, { recursive: true, force: true });
+  });
+
+  /** Whether process `pid` is still running. */
+  function alive(pid) {
+    try {
+      process.kill(pid, 0);
+      return true;
+    } catch {
+      return false;
+    }
+  }
+
+  it('closes the browser and stops openerpd when an action throws', async () => {
+    // The fixture's only test throws; the ownServerSession hooks the
+    // suites above use must still clean up after it.
+    tmp = mkdtempSync(join(tmpdir(), 'openerp-teardown-'));
+    const report = join(tmp, 'report.json');
+    const pagesBefore = LIGHTPANDA_WS ? (await cdpPages()).map(t => t.id) : null;
+    // Without NODE_TEST_CONTEXT the child reports as a standalone run.
+    const { NODE_TEST_CONTEXT, ...env } = process.env;
+    const res = spawnSync(process.execPath, ['--test', '--test-reporter=tap', FAILING_ACTION], {
+      env: { ...env, TEARDOWN_REPORT: report },
+      encoding: 'utf8',
+      timeout: 60_000,
+    });
+    assert.equal(res.status, 1, `fixture run: ${res.stdout}${res.stderr}`);
+    assert.match(res.stdout, /action blew up/, "the action's error reaches the test");
+    assert.doesNotMatch(res.stdout, /hookFailed/, 'cleanup hooks passed');
+
+    const { baseUrl, serverPid, browserPid } = JSON.parse(readFileSync(report, 'utf8'));
+    assert.ok(!alive(serverPid), `openerpd (pid ${serverPid}) exited`);
+    await assert.rejects(fetch(`${baseUrl}/health`), 'openerpd no longer listening');
+    if (browserPid) {
+      // Launched Chromium is ours to stop.
+      assert.ok(!alive(browserPid), `browser (pid ${browserPid}) exited`);
+    } else {
+      // A shared LIGHTPANDA_WS browser keeps running; only our page goes.
+      assert.deepEqual((await cdpPages()).map(t => t.id), pagesBefore, 'page closed');
+    }
+  });
+});
+
+// Runs after the suites above, so their `after` cleanup is covered too.
+describe('CDP session cleanup (Lightpanda)', {
+  skip: skip || (LIGHTPANDA_WS ? false : 'LIGHTPANDA_WS not set'),
+}, () => {
+  it('leaves no page sessions open after cleanup', async () => {
+    const { default: puppeteer } = await import('puppeteer');
+    assert.deepEqual(await cdpPages(), [], 'sessions left by the dashboard suite');
+
+    for (let round = 1; round <= 2; round++) {
+      const browser = await puppeteer.connect({ browserWSEndpoint: LIGHTPANDA_WS });
+      const page = await browser.newPage();
+      await page.goto(`${BASE_URL}/`, { waitUntil: 'networkidle0' });
+      assert.ok((await cdpPages()).length > 0, `round ${round}: page is listed while open`);
+
+      await page.close();
+      await browser.disconnect();
+      assert.deepEqual(await cdpPages(), [], `round ${round}: sessions after cleanup`);
+    }
+  });
+  it('lists open pages with a debugger URL for their session', async () => {
+    const { default: puppeteer } = await import('puppeteer');
+    const endpoint = new URL(LIGHTPANDA_WS);
+    const browser = await puppeteer.connect({ browserWSEndpoint: LIGHTPANDA_WS });
+    const page = await browser.newPage();
+    try {
+      await page.goto(`${BASE_URL}/`, { waitUntil: 'networkidle0' });
+      const pages = await cdpPages();
+      assert.ok(pages.length > 0, 'open page is listed');
+      for (const t of pages) {
+        assert.equal(t.type, 'page');
+        assert.ok(t.id, `target has an id: ${JSON.stringify(t)}`);
+        assert.equal(typeof t.webSocketDebuggerUrl, 'string',
+          `target ${t.id} has webSocketDebuggerUrl`);
+        // Same CDP server we were pointed at, addressed by this target's ID.
+        const ws = new URL(t.webSocketDebuggerUrl);
+        assert.ok(['ws:', 'wss:'].includes(ws.protocol), t.webSocketDebuggerUrl);
+        assert.equal(ws.host, endpoint.host, `${t.webSocketDebuggerUrl} vs ${LIGHTPANDA_WS}`);
+        assert.ok(ws.pathname.includes(t.id),
+          `${t.webSocketDebuggerUrl} names target ${t.id}`);
+      }
+    } finally {
+      await page.close();
+      await browser.disconnect();
+    }
+  });
 });
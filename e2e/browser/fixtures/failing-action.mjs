@@ -0,0 +1,31 @@
+/**
+ * Child suite for "Teardown after a failing action" in dashboard.test.mjs,
+ * which runs it with `node --test`. Its only test throws mid-action, so
+ * all cleanup is left to the shared `ownServerSession` hooks. Writes the
+ * server URL and pids it started to TEARDOWN_REPORT for the parent to
+ * check afterwards.
+ */
+
+import { describe, it, before } from 'node:test';
+import { writeFileSync } from 'node:fs';
+import { ownServerSession } from '../session.mjs';
+
+describe('Failing action', () => {
+  const session = ownServerSession('teardown');
+
+  before(() => {
+    writeFileSync(process.env.TEARDOWN_REPORT, JSON.stringify({
+      baseUrl: session.server.baseUrl,
+      serverPid: session.server.proc.pid,
+      // Null for a shared LIGHTPANDA_WS browser.
+      browserPid: session.browser.process()?.pid ?? null,
+    }));
+  });
+
+  it('throws mid-action', async () => {
+    await session.page.goto(`${session.server.baseUrl}/`, { waitUntil: 'networkidle0' });
+    await session.page.evaluate(() => {
+      throw new Error('action blew up');
+    });
+  });
+});
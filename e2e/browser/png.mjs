@@ -0,0 +1,71 @@
+/**
+ * Minimal PNG decoding and comparison for the dashboard screenshot
+ * baseline, so the suite needs no image library. Covered by png.test.mjs.
+ */
+
+import assert from 'node:assert/strict';
+import { inflateSync } from 'node:zlib';
+
+/**
+ * Decode an 8-bit, non-interlaced RGB or RGBA PNG (what Chromium
+ * screenshots are) into `{ width, height, rgba }`.
+ */
+export function decodePng(buf) {
+  let width, height, channels;
+  const idat = [];
+  for (let off = 8; off < buf.length;) {
+    const len = buf.readUInt32BE(off);
+    const type = buf.toString('latin1', off + 4, off + 8);
+    const data = buf.subarray(off + 8, off + 8 + len);
+    if (type === 'IHDR') {
+      width = data.readUInt32BE(0);
+      height = data.readUInt32BE(4);
+      const [depth, color, , , interlace] = data.subarray(8);
+      assert.ok(depth === 8 && (color === 2 || color === 6) && !interlace,
+        `unsupported PNG: depth ${depth}, color type ${color}, interlace ${interlace}`);
+      channels = color === 6 ? 4 : 3;
+    } else if (type === 'IDAT') {
+      idat.push(data);
+    }
+    off += 12 + len;
+  }
+  const raw = inflateSync(Buffer.concat(idat));
+  const stride = width * channels;
+  const px = Buffer.alloc(stride * height);
+  for (let y = 0; y < height; y++) {
+    const filter = raw[y * (stride + 1)];
+    const line = raw.subarray(y * (stride + 1) + 1, (y + 1) * (stride + 1));
+    for (let x = 0; x < stride; x++) {
+      const a = x >= channels ? px[y * stride + x - channels] : 0;
+      const b = y > 0 ? px[(y - 1) * stride + x] : 0;
+      const c = x >= channels && y > 0 ? px[(y - 1) * stride + x - channels] : 0;
+      let pred = 0;
+      if (filter === 1) pred = a;
+      else if (filter === 2) pred = b;
+      else if (filter === 3) pred = (a + b) >> 1;
+      else if (filter === 4) {
+        const p = a + b - c;
+        const pa = Math.abs(p - a), pb = Math.abs(p - b), pc = Math.abs(p - c);
+        pred = pa <= pb && pa <= pc ? a : pb <= pc ? b : c;
+      }
+      px[y * stride + x] = (line[x] + pred) & 0xff;
+    }
+  }
+  const rgba = Buffer.alloc(width * height * 4, 0xff);
+  for (let i = 0; i < width * height; i++) {
+    px.copy(rgba, i * 4, i * channels, i * channels + channels);
+  }
+  return { width, height, rgba };
+}
+
+/** Fraction (0-1) of pixels whose RGBA differ by more than `tolerance`. */
+export function pixelDiff(a, b, tolerance = 16) {
+  if (a.width !== b.width || a.height !== b.height) return 1;
+  let differing = 0;
+  for (let i = 0; i < a.rgba.length; i += 4) {
+    for (let ch = 0; ch < 4; ch++) {
+      if (Math.abs(a.rgba[i + ch] - b.rgba[i + ch]) > tolerance) { differing++; break; }
+    }
+  }
+  return differing / (a.width * a.height);
+}
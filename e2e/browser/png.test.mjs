@@ -0,0 +1,134 @@
+/**
+ * Unit tests for png.mjs, the decoder behind the dashboard screenshot
+ * baseline. Needs no server or browser, so it runs even where the
+ * dashboard suite is skipped.
+ *
+ * Images are encoded here, one scanline per PNG filter type, so every
+ * filter the decoder undoes is checked against known pixels.
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert/strict';
+import { deflateSync } from 'node:zlib';
+import { decodePng, pixelDiff } from './png.mjs';
+
+function chunk(type, data) {
+  const head = Buffer.alloc(8);
+  head.writeUInt32BE(data.length, 0);
+  head.write(type, 4, 'latin1');
+  // The decoder does not check CRCs; zeros keep the encoder short.
+  return Buffer.concat([head, data, Buffer.alloc(4)]);
+}
+
+/** Paeth predictor, as the PNG spec defines it. */
+function paeth(a, b, c) {
+  const p = a + b - c;
+  const pa = Math.abs(p - a), pb = Math.abs(p - b), pc = Math.abs(p - c);
+  return pa <= pb && pa <= pc ? a : pb <= pc ? b : c;
+}
+
+/**
+ * Encode `pixels` (width * height * channels bytes) as a PNG, filtering
+ * row y with `filters[y]`.
+ */
+function encodePng({ width, height, channels, pixels, filters, depth = 8, interlace = 0 }) {
+  const stride = width * channels;
+  const raw = Buffer.alloc((stride + 1) * height);
+  for (let y = 0; y < height; y++) {
+    const filter = filters[y % filters.length];
+    raw[y * (stride + 1)] = filter;
+    for (let x = 0; x < stride; x++) {
+      const at = (yy, xx) => (yy < 0 || xx < 0 ? 0 : pixels[yy * stride + xx]);
+      const a = at(y, x - channels), b = at(y - 1, x), c = at(y - 1, x - channels);
+      const pred = [0, a, b, (a + b) >> 1, paeth(a, b, c)][filter];
+      raw[y * (stride + 1) + 1 + x] = (pixels[y * stride + x] - pred) & 0xff;
+    }
+  }
+  const ihdr = Buffer.alloc(13);
+  ihdr.writeUInt32BE(width, 0);
+  ihdr.writeUInt32BE(height, 4);
+  ihdr.set([depth, channels === 4 ? 6 : 2, 0, 0, interlace], 8);
+  return Buffer.concat([
+    Buffer.from([0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a]),
+    chunk('IHDR', ihdr),
+    chunk('IDAT', deflateSync(raw)),
+    chunk('IEND', Buffer.alloc(0)),
+  ]);
+}
+
+/** Deterministic, varied pixel bytes (so every predictor matters). */
+function pattern(n) {
+  return Buffer.from(Array.from({ length: n }, (_, i) => (i * 97 + (i >> 3) * 31) & 0xff));
+}
+
+function image(width, height, rgba) {
+  return { width, height, rgba: Buffer.from(rgba) };
+}
+
+describe('decodePng', () => {
+  it('undoes every filter type on RGBA rows', () => {
+    const width = 7, height = 10;
+    const pixels = pattern(width * height * 4);
+    const png = encodePng({ width, height, channels: 4, pixels, filters: [0, 1, 2, 3, 4] });
+    const img = decodePng(png);
+    assert.equal(img.width, width);
+    assert.equal(img.height, height);
+    assert.deepEqual(img.rgba, pixels);
+  });
+
+  it('expands RGB to opaque RGBA', () => {
+    const width = 5, height = 5;
+    const pixels = pattern(width * height * 3);
+    const img = decodePng(encodePng({ width, height, channels: 3, pixels, filters: [4, 3, 2, 1, 0] }));
+    for (let i = 0; i < width * height; i++) {
+      assert.deepEqual([...img.rgba.subarray(i * 4, i * 4 + 4)],
+        [...pixels.subarray(i * 3, i * 3 + 3), 0xff], `pixel ${i}`);
+    }
+  });
+
+  it('reads image data split across several IDAT chunks', () => {
+    const width = 4, height = 6;
+    const pixels = pattern(width * height * 4);
+    const png = encodePng({ width, height, channels: 4, pixels, filters: [2, 4] });
+    // Re-split the single IDAT payload into two chunks.
+    const idatAt = png.indexOf('IDAT', 0, 'latin1') - 4;
+    const len = png.readUInt32BE(idatAt);
+    const data = png.subarray(idatAt + 8, idatAt + 8 + len);
+    const split = Buffer.concat([
+      png.subarray(0, idatAt),
+      chunk('IDAT', data.subarray(0, len >> 1)),
+      chunk('IDAT', data.subarray(len >> 1)),
+      png.subarray(idatAt + 12 + len),
+    ]);
+    assert.deepEqual(decodePng(split).rgba, pixels);
+  });
+
+  it('rejects formats it cannot decode', () => {
+    const pixels = pattern(2 * 2 * 4);
+    assert.throws(() => decodePng(encodePng({ width: 2, height: 2, channels: 4, pixels, filters: [0], depth: 16 })),
+      /unsupported PNG/);
+    assert.throws(() => decodePng(encodePng({ width: 2, height: 2, channels: 4, pixels, filters: [0], interlace: 1 })),
+      /unsupported PNG/);
+  });
+});
+
+describe('pixelDiff', () => {
+  const base = [10, 20, 30, 255, 40, 50, 60, 255, 70, 80, 90, 255, 0, 0, 0, 255];
+
+  it('is 0 for identical images and 1 for different sizes', () => {
+    assert.equal(pixelDiff(image(2, 2, base), image(2, 2, base)), 0);
+    assert.equal(pixelDiff(image(2, 2, base), image(4, 1, base)), 1);
+  });
+
+  it('counts pixels whose channels differ by more than the tolerance', () => {
+    const near = [...base];
+    near[0] += 16; // within the default tolerance
+    assert.equal(pixelDiff(image(2, 2, base), image(2, 2, near)), 0);
+
+    const off = [...base];
+    off[4] += 17; // pixel 1, red
+    off[15] = 0; // pixel 3, alpha
+    assert.equal(pixelDiff(image(2, 2, base), image(2, 2, off)), 0.5);
+    assert.equal(pixelDiff(image(2, 2, base), image(2, 2, off), 255), 0);
+  });
+});
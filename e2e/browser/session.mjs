@@ -0,0 +1,84 @@
+/**
+ * Browser and own-server setup shared by dashboard.test.mjs and its
+ * fixtures (see fixtures/failing-action.mjs).
+ */
+
+import { before, after } from 'node:test';
+import assert from 'node:assert/strict';
+import { mkdtempSync, rmSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import { createContext, startServer } from '../shared/openerpd.mjs';
+
+// CDP browser to drive instead of Chromium; the runner passes WS_URL on
+// as LIGHTPANDA_WS, and either works when running the test directly.
+export const LIGHTPANDA_WS = process.env.WS_URL || process.env.LIGHTPANDA_WS;
+
+/** Connect to LIGHTPANDA_WS, or launch Chromium (puppeteer auto-downloads it). */
+export async function openBrowser() {
+  // Imported lazily so a skipped run doesn't need puppeteer installed.
+  const { default: puppeteer } = await import('puppeteer');
+  if (LIGHTPANDA_WS) {
+    // Reuse an already-running CDP browser.
+    return puppeteer.connect({ browserWSEndpoint: LIGHTPANDA_WS });
+  }
+  return puppeteer.launch({
+    headless: true,
+    args: [
+      '--no-sandbox', '--disable-setuid-sandbox', '--disable-dev-shm-usage',
+      ...(process.env.BROWSER_ARGS || '').split(/\s+/).filter(Boolean),
+    ],
+  });
+}
+
+/** Undo `openBrowser`: a shared CDP browser is only disconnected from. */
+export async function closeBrowser(browser, page) {
+  if (LIGHTPANDA_WS) {
+    try {
+      if (page) await page.close();
+    } finally {
+      // A page that fails to close must not leave the connection open.
+      if (browser) await browser.disconnect();
+    }
+  } else if (browser) {
+    await browser.close();
+  }
+}
+
+/**
+ * Register `before`/`after` hooks on the enclosing suite that give it an
+ * openerpd of its own, from a fresh `name` context under a temp dir, and a
+ * browser page. `configure(configPath)` may edit the server config before
+ * it starts. Returns `{ configPath, server, browser, page, pageErrors }`,
+ * filled in by `before`; a suite that restarts the server stores the new
+ * one in `server` so `after` stops it.
+ */
+export function ownServerSession(name, { configure } = {}) {
+  const session = { configPath: null, server: null, browser: null, page: null, pageErrors: [] };
+  let tmp;
+
+  before(async () => {
+    tmp = mkdtempSync(join(tmpdir(), `openerp-${name}-`));
+    const ctx = createContext(tmp, name);
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+    session.configPath = ctx.configPath;
+    configure?.(ctx.configPath);
+    session.server = await startServer(ctx.configPath);
+
+    session.browser = await openBrowser();
+    session.page = await session.browser.newPage();
+    session.page.on('pageerror', (err) => session.pageErrors.push(err.message));
+  });
+
+  after(async () => {
+    try {
+      await closeBrowser(session.browser, session.page);
+    } finally {
+      // The server goes even if the browser fails to close.
+      await session.server?.stop();
+      if (tmp) rmSync(tmp, { recursive: true, force: true });
+    }
+  });
+
+  return session;
+}
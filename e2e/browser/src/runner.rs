@@ -1,65 +1,151 @@
 //! E2E Browser Test Runner.
 //!
 //! Starts openerpd, installs npm deps, then runs the Puppeteer test.
-//! Puppeteer auto-downloads and manages Chromium.
+//! Puppeteer auto-downloads and manages Chromium. The test run as a whole
+//! must finish within 5 minutes (`SUITE_TIMEOUT`) or the runner fails it.
 //!
 //! Usage:
-//!   bazel run //e2e/browser:runner
+//!   bazel run //e2e/browser:runner [-- --update-baseline]
+//!
+//! The dashboard screenshot is compared against the committed
+//! e2e/browser/testdata/dashboard.png, and a missing baseline fails the
+//! run. `--update-baseline` writes it from this run instead, into the
+//! source tree under `bazel run`, ready to commit.
+//!
+//! Set `BASE_URL` to reuse an already-running openerpd instead of starting
+//! one, and `WS_URL` to drive an existing CDP browser such as Lightpanda
+//! (`LIGHTPANDA_WS`, the name the test itself reads, is accepted too). With
+//! `BASE_URL` the own-server tests are skipped, since they need the
+//! openerpd/openerp binaries to run a server they can stop.
+//!
+//! Environment overrides (see `TestEnv::from_env`):
+//!   ROOT_PASS       root password for the test context
+//!   OPENERPD_ENV    extra server env, `K=V,K2=V2`
+//!   BROWSER_ARGS    extra browser flags (space-separated), passed to the test
+//!   TLS_CA_FILE     CA bundle to trust; requires an https `BASE_URL`
+//!   WS_URL          CDP browser to connect to (falls back to LIGHTPANDA_WS)
 
 use std::net::TcpListener;
-use std::path::PathBuf;
-use std::process::{Child, Command, Stdio};
+use std::path::{Path, PathBuf};
+use std::process::{Child, Command, ExitStatus, Stdio};
 use std::time::{Duration, Instant};
 
 const ROOT_PASS: &str = "openerp123";
 
-fn main() {
-    eprintln!("[runner] Starting E2E browser test...");
+/// Hard budget for the whole Puppeteer run, so a hung browser or page
+/// fails the job instead of stalling CI until its own timeout.
+const SUITE_TIMEOUT: Duration = Duration::from_secs(5 * 60);
 
-    let openerpd_bin = find_binary("openerpd", "OPENERPD_PATH");
-    let openerp_bin = find_binary("openerp", "OPENERP_PATH");
-    let test_file = find_test_file();
+/// Test environment settings. `TestEnv::default()` is what the runner has
+/// always used; each `with_*` method overrides one setting.
+#[derive(Debug, Clone)]
+struct TestEnv {
+    root_pass: String,
+    /// Extra environment for openerpd (applied after `RUST_LOG=warn`).
+    server_env: Vec<(String, String)>,
+    /// Extra flags for the browser the test launches.
+    browser_args: Vec<String>,
+    /// CA bundle for an https server (trusted via NODE_EXTRA_CA_CERTS).
+    tls_ca_file: Option<PathBuf>,
+    /// CDP endpoint of an already-running browser, instead of Chromium.
+    ws_url: Option<String>,
+}
 
-    eprintln!("[runner] openerpd:   {}", openerpd_bin.display());
-    eprintln!("[runner] openerp:    {}", openerp_bin.display());
-    eprintln!("[runner] test file:  {}", test_file.display());
+impl Default for TestEnv {
+    fn default() -> Self {
+        Self {
+            root_pass: ROOT_PASS.to_string(),
+            server_env: Vec::new(),
+            browser_args: Vec::new(),
+            tls_ca_file: None,
+            ws_url: None,
+        }
+    }
+}
 
-    // Create test context.
-    let tmp = tempfile::tempdir().expect("create tmpdir");
-    let config_dir = tmp.path().join("config");
-    let data_dir = tmp.path().join("data");
-    let client_config = tmp.path().join("client.toml");
+impl TestEnv {
+    fn with_root_pass(mut self, pass: impl Into<String>) -> Self {
+        self.root_pass = pass.into();
+        self
+    }
 
-    let status = Command::new(&openerp_bin)
-        .args([
-            "--config", client_config.to_str().unwrap(),
-            "context", "create", "e2e-br",
-            "--config-dir", config_dir.to_str().unwrap(),
-            "--data-dir", data_dir.to_str().unwrap(),
-            "--password", ROOT_PASS,
-        ])
-        .status()
-        .expect("run openerp context create");
-    assert!(status.success(), "openerp context create failed");
+    fn with_server_env(mut self, env: impl IntoIterator<Item = (String, String)>) -> Self {
+        self.server_env.extend(env);
+        self
+    }
 
-    let server_config = config_dir.join("e2e-br.toml");
+    fn with_browser_args(mut self, args: impl IntoIterator<Item = String>) -> Self {
+        self.browser_args.extend(args);
+        self
+    }
 
-    // Start openerpd.
-    let erp_port = free_port();
-    let erp_addr = format!("127.0.0.1:{erp_port}");
-    let base_url = format!("http://{erp_addr}");
-    eprintln!("[runner] Starting openerpd on {erp_addr}...");
+    /// openerpd only serves plain HTTP, so this is for an external https
+    /// server given via `BASE_URL`.
+    fn with_tls_ca(mut self, ca_file: impl Into<PathBuf>) -> Self {
+        self.tls_ca_file = Some(ca_file.into());
+        self
+    }
 
-    let mut openerpd = Command::new(&openerpd_bin)
-        .args(["-c", server_config.to_str().unwrap(), "--listen", &erp_addr])
-        .env("RUST_LOG", "warn")
-        .stdout(Stdio::null())
-        .stderr(Stdio::piped())
-        .spawn()
-        .expect("start openerpd");
+    fn with_ws_url(mut self, url: impl Into<String>) -> Self {
+        self.ws_url = Some(url.into());
+        self
+    }
 
-    wait_for_health(&base_url, Duration::from_secs(30));
-    eprintln!("[runner] openerpd ready");
+    /// Defaults plus any overrides from the environment.
+    fn from_env() -> Self {
+        let var = |k: &str| std::env::var(k).ok().filter(|v| !v.is_empty());
+        let mut env = TestEnv::default();
+        if let Some(pass) = var("ROOT_PASS") {
+            env = env.with_root_pass(pass);
+        }
+        if let Some(list) = var("OPENERPD_ENV") {
+            env = env.with_server_env(list.split(',').filter_map(|kv| {
+                let (k, v) = kv.split_once('=')?;
+                Some((k.trim().to_string(), v.trim().to_string()))
+            }));
+        }
+        if let Some(args) = var("BROWSER_ARGS") {
+            env = env.with_browser_args(args.split_whitespace().map(String::from));
+        }
+        if let Some(ca) = var("TLS_CA_FILE") {
+            env = env.with_tls_ca(ca);
+        }
+        if let Some(url) = var("WS_URL").or_else(|| var("LIGHTPANDA_WS")) {
+            env = env.with_ws_url(url);
+        }
+        env
+    }
+}
+
+fn main() {
+    eprintln!("[runner] Starting E2E browser test...");
+    let env = TestEnv::from_env();
+    let update_baseline = std::env::args().skip(1).any(|a| a == "--update-baseline");
+
+    let test_file = find_test_file();
+    eprintln!("[runner] test file:  {}", test_file.display());
+
+    let tmp = tempfile::tempdir().expect("create tmpdir");
+
+    // Reuse a server that is already running (manual debugging, or CI
+    // jobs that start openerpd separately); otherwise start our own.
+    let mut openerpd = None;
+    let base_url = match std::env::var("BASE_URL") {
+        Ok(url) if !url.is_empty() => {
+            eprintln!("[runner] Using existing server at {url}");
+            wait_for_health(&url, Duration::from_secs(30));
+            url
+        }
+        _ => {
+            assert!(
+                env.tls_ca_file.is_none(),
+                "TLS_CA_FILE needs an https BASE_URL; openerpd serves plain HTTP"
+            );
+            let (child, url) = start_openerpd(tmp.path(), &env);
+            openerpd = Some(child);
+            url
+        }
+    };
 
     // Resolve real paths (Bazel runfiles are symlinks).
     let test_dir = test_file.parent().unwrap().to_path_buf();
@@ -67,8 +153,11 @@ fn main() {
         .unwrap_or_else(|_| test_dir.clone());
 
     // Install npm deps in a temp location to avoid polluting workspace.
-    let npm_dir = tmp.path().join("npm");
+    // Mirror the e2e/ layout: the test imports ../shared/openerpd.mjs.
+    let npm_dir = tmp.path().join("e2e/browser");
+    let shared_dir = tmp.path().join("e2e/shared");
     std::fs::create_dir_all(&npm_dir).expect("create npm dir");
+    std::fs::create_dir_all(&shared_dir).expect("create shared dir");
     std::fs::copy(src_test_dir.join("package.json"), npm_dir.join("package.json"))
         .expect("copy package.json");
     std::fs::copy(
@@ -76,6 +165,16 @@ fn main() {
         npm_dir.join("dashboard.test.mjs"),
     )
     .expect("copy test file");
+    for file in ["session.mjs", "png.mjs", "png.test.mjs", "fixtures/failing-action.mjs"] {
+        let dest = npm_dir.join(file);
+        std::fs::create_dir_all(dest.parent().unwrap()).expect("create fixtures dir");
+        std::fs::copy(src_test_dir.join(file), dest).expect("copy test module");
+    }
+    std::fs::copy(
+        src_test_dir.parent().unwrap().join("shared/openerpd.mjs"),
+        shared_dir.join("openerpd.mjs"),
+    )
+    .expect("copy shared helpers");
 
     eprintln!("[runner] Installing npm deps in {}...", npm_dir.display());
     let status = Command::new("npm")
@@ -88,21 +187,49 @@ fn main() {
     let real_test_dir = npm_dir.clone();
     let real_test_file = npm_dir.join("dashboard.test.mjs");
 
+    // The baseline lives in the source tree, so an update lands in git.
+    // Only --update-baseline writes it; otherwise it is just read.
+    let baseline = match std::env::var("BUILD_WORKSPACE_DIRECTORY") {
+        Ok(ws) => PathBuf::from(ws).join("e2e/browser/testdata/dashboard.png"),
+        Err(_) => src_test_dir.join("testdata/dashboard.png"),
+    };
+
     // Run Puppeteer test from the real directory (where node_modules is).
     let real_test_file = real_test_dir.join("dashboard.test.mjs");
     eprintln!("[runner] Running Puppeteer test...");
-    let status = Command::new("node")
-        .args(["--test", real_test_file.to_str().unwrap()])
+    let mut node = Command::new("node");
+    node.args(["--test", real_test_file.to_str().unwrap(), "png.test.mjs"])
         .current_dir(&real_test_dir)
         .env("BASE_URL", &base_url)
-        .env("ROOT_PASS", ROOT_PASS)
-        .status()
-        .expect("run node test");
+        .env("ROOT_PASS", &env.root_pass)
+        .env("BROWSER_ARGS", env.browser_args.join(" "))
+        .env("OPENERP_E2E_ENABLED", "1")
+        .env("SCREENSHOT_BASELINE", &baseline)
+        .env("UPDATE_BASELINE", if update_baseline { "1" } else { "0" });
+    if openerpd.is_some() {
+        // The outage test starts and stops a server of its own.
+        node.env("OPENERPD_BIN", find_binary("openerpd", "OPENERPD_PATH"))
+            .env("OPENERP_BIN", find_binary("openerp", "OPENERP_PATH"));
+    }
+    if let Some(ca) = &env.tls_ca_file {
+        node.env("NODE_EXTRA_CA_CERTS", ca);
+    }
+    if let Some(url) = &env.ws_url {
+        node.env("LIGHTPANDA_WS", url);
+    }
+    let mut child = node.spawn().expect("run node test");
+    let status = wait_with_timeout(&mut child, SUITE_TIMEOUT);
 
-    // Kill openerpd.
-    let _ = openerpd.kill();
-    let _ = openerpd.wait();
+    // Kill openerpd (only if we started it).
+    if let Some(mut child) = openerpd {
+        let _ = child.kill();
+        let _ = child.wait();
+    }
 
+    let Some(status) = status else {
+        eprintln!("[runner] Tests TIMED OUT after {}s", SUITE_TIMEOUT.as_secs());
+        std::process::exit(1);
+    };
     if status.success() {
         eprintln!("[runner] All tests passed!");
     } else {
@@ -112,6 +239,88 @@ fn main() {
     std::process::exit(status.code().unwrap_or(1));
 }
 
+/// Wait for `child` until `timeout`, then stop it. Returns `None` if it
+/// had to be stopped.
+///
+/// On Unix it gets SIGTERM and a few seconds first: puppeteer closes the
+/// browser it launched on SIGTERM, but not on SIGKILL.
+fn wait_with_timeout(child: &mut Child, timeout: Duration) -> Option<ExitStatus> {
+    if let Some(status) = wait_until(child, Instant::now() + timeout) {
+        return Some(status);
+    }
+    #[cfg(unix)]
+    {
+        let _ = Command::new("kill").arg(child.id().to_string()).status();
+        if wait_until(child, Instant::now() + Duration::from_secs(5)).is_some() {
+            return None;
+        }
+    }
+    let _ = child.kill();
+    let _ = child.wait();
+    None
+}
+
+/// Poll `child` until it exits or `deadline` passes.
+fn wait_until(child: &mut Child, deadline: Instant) -> Option<ExitStatus> {
+    while Instant::now() < deadline {
+        if let Some(status) = child.try_wait().expect("wait for node") {
+            return Some(status);
+        }
+        std::thread::sleep(Duration::from_millis(200));
+    }
+    None
+}
+
+// ── Server setup ──
+
+/// Create a test context under `tmp` and start openerpd on a free port.
+fn start_openerpd(tmp: &Path, env: &TestEnv) -> (Child, String) {
+    let openerpd_bin = find_binary("openerpd", "OPENERPD_PATH");
+    let openerp_bin = find_binary("openerp", "OPENERP_PATH");
+
+    eprintln!("[runner] openerpd:   {}", openerpd_bin.display());
+    eprintln!("[runner] openerp:    {}", openerp_bin.display());
+
+    // Create test context.
+    let config_dir = tmp.join("config");
+    let data_dir = tmp.join("data");
+    let client_config = tmp.join("client.toml");
+
+    let status = Command::new(&openerp_bin)
+        .args([
+            "--config", client_config.to_str().unwrap(),
+            "context", "create", "e2e-br",
+            "--config-dir", config_dir.to_str().unwrap(),
+            "--data-dir", data_dir.to_str().unwrap(),
+            "--password", &env.root_pass,
+        ])
+        .status()
+        .expect("run openerp context create");
+    assert!(status.success(), "openerp context create failed");
+
+    let server_config = config_dir.join("e2e-br.toml");
+
+    // Start openerpd.
+    let erp_port = free_port();
+    let erp_addr = format!("127.0.0.1:{erp_port}");
+    let base_url = format!("http://{erp_addr}");
+    eprintln!("[runner] Starting openerpd on {erp_addr}...");
+
+    let child = Command::new(&openerpd_bin)
+        .args(["-c", server_config.to_str().unwrap(), "--listen", &erp_addr])
+        .env("RUST_LOG", "warn")
+        .envs(env.server_env.iter().map(|(k, v)| (k, v)))
+        .stdout(Stdio::null())
+        .stderr(Stdio::piped())
+        .spawn()
+        .expect("start openerpd");
+
+    wait_for_health(&base_url, Duration::from_secs(30));
+    eprintln!("[runner] openerpd ready");
+
+    (child, base_url)
+}
+
 // ── Binary discovery ──
 
 fn find_in_runfiles(candidates: &[&str], env_key: &str, desc: &str) -> PathBuf {
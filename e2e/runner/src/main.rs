@@ -1,57 +1,557 @@
 //! E2E test runner — cross-platform Rust binary.
 //!
-//! 1. Builds openerpd + openerp CLI via Bazel
-//! 2. Creates a test context using `openerp context create --password`
-//! 3. Starts openerpd on a random port
-//! 4. Waits for /health to return 200
-//! 5. Runs Rust unit tests via Bazel
+//! Numbered like the "Step N" labels it logs:
+//!
+//! 1. Builds openerpd + openerp CLI via Bazel (1b: the hermetic check,
+//!    with `--check-hermetic`)
+//! 2. Runs Rust unit tests via Bazel
+//! 3. Creates a test context using `openerp context create --password`
+//! 4. Starts openerpd on a random port and waits for /health to return 200
+//! 5. Installs the E2E Node deps (`npm ci` with a lockfile)
 //! 6. Runs Node.js E2E tests
-//! 7. Kills server, cleans up
+//! 7. Writes per-test timings to e2e/test-results/timing.json and, with
+//!    `--max-test-ms`, fails on any test slower than that
+//!
+//! Then it kills the server and cleans up.
+//!
+//! Steps 1 and 2 also write Bazel's per-target results (built, test
+//! status, test duration) to e2e/test-results/bep_summary.json; step 2
+//! adds each Rust test case's outcome, parsed from the targets'
+//! bazel-testlogs, to e2e/test-results/rust_tests.json. A failed case
+//! also gets its output there and in the runner log, e.g. the left/right
+//! of a golden test's failed `assert_eq!`.
+//!
+//! Flags:
+//!   --max-test-ms <N>   fail if any single Node.js test exceeds N ms (off by
+//!                       default: suites that start their own openerpd are slow)
+//!   --verbose           RUST_LOG=debug for openerpd, --verbose_failures for Bazel
+//!   --check-hermetic    after step 1, build openerpd twice from clean and
+//!                       fail if the binaries' SHA-256 differ
+//!   --profile <cpu|mem> write a step-N.json snapshot per step: wall + CPU
+//!                       time (cpu) or RSS / peak RSS (mem) of the runner
+//!   --profile-dir <dir> where to write profiles (default e2e/test-results/profile)
+//!   --clean             `bazel clean --expunge` before building (step 1),
+//!                       to drop stale outputs from another branch
+//!   --step <N>          start at pipeline step N (1-7, the "Step N" labels
+//!                       in the output), assuming earlier steps already ran;
+//!                       steps 3-4 (temp context + server) always run
+//!   --check-deps        before any step, check that bazel, node and npm
+//!                       are on PATH and new enough; list every problem
+//!   --bazel <path>      Bazel binary to run (default `bazel` on PATH),
+//!                       e.g. bazelisk
+//!   --report-dir <dir>  write every artefact (bep_summary.json,
+//!                       rust_tests.json, timing.json, results.tap /
+//!                       results.xml, openerpd.log, profiles unless
+//!                       --profile-dir is given) to <dir> instead of
+//!                       e2e/test-results, and print its path at the end
+//!   --log-format <text|json>
+//!                       runner messages as logfmt-style `key=value` lines
+//!                       (default) or one JSON object per line, on stderr
+//!   --log-level <debug|info|warn|error>
+//!                       drop runner messages below this level (default
+//!                       info); debug also logs each command run
+//!   --smoke-only        quick pass/fail signal (e.g. a pre-commit hook):
+//!                       build, //rust/lib/core:core_test only, context +
+//!                       server + /health, tests/01-login.test.mjs only,
+//!                       and no timing gate
 
-use std::io::{BufRead, BufReader};
+use std::collections::BTreeMap;
+use std::io::{BufRead, BufReader, Read, Write};
 use std::net::TcpListener;
 use std::path::{Path, PathBuf};
 use std::process::{Child, Command, Stdio};
+use std::sync::{Arc, Mutex, OnceLock};
 use std::time::{Duration, Instant};
 
 const ROOT_PASS: &str = "openerp123";
 
+/// Oldest Node.js the E2E tests support (oldest maintained LTS line;
+/// puppeteer-core 24 and the `node:test` reporters need 18+).
+const MIN_NODE: (u32, u32, u32) = (20, 0, 0);
+
+/// Rust test targets run in step 2. The DSL golden tests pin the admin
+/// API's behaviour end to end, so the E2E server changes are checked
+/// against them on every run.
+const RUST_TEST_TARGETS: &[&str] = &[
+    "//rust/lib/dsl/golden:golden_test",
+    "//rust/lib/dsl/store:store_test",
+    "//rust/lib/dsl/types:types_test",
+    "//rust/lib/dsl/macro_test:macro_test",
+    "//rust/lib/core:core_test",
+    "//rust/mod/auth:auth_test",
+    "//rust/mod/pms:pms_test",
+    "//rust/mod/task:task_test",
+];
+
+/// Node.js test files run in step 6, relative to e2e/.
+const E2E_TEST_FILES: &[&str] = &[
+    "tests/01-login.test.mjs",
+    "tests/02-dashboard-crud.test.mjs",
+    "tests/03-api-auth.test.mjs",
+    "tests/04-user-login.test.mjs",
+    "tests/05-facet-api.test.mjs",
+    "tests/06-pms-actions.test.mjs",
+    "tests/07-task-actions.test.mjs",
+    "tests/08-put-edit-api.test.mjs",
+    "tests/09-user-password-login.test.mjs",
+    "tests/10-patch-api.test.mjs",
+    "tests/11-cli.test.mjs",
+    "tests/12-server-health.test.mjs",
+    "tests/13-list-count.test.mjs",
+    "tests/14-error-codes.test.mjs",
+    "tests/15-hardening.test.mjs",
+    "tests/16-field-values.test.mjs",
+    "tests/17-http-caching.test.mjs",
+    "tests/18-user-lifecycle.test.mjs",
+];
+
+/// `--smoke-only` subsets of `RUST_TEST_TARGETS` and `E2E_TEST_FILES`.
+const SMOKE_RUST_TEST_TARGETS: &[&str] = &["//rust/lib/core:core_test"];
+const SMOKE_TEST_FILES: &[&str] = &["tests/01-login.test.mjs"];
+
+/// Runner command-line options.
+#[derive(Debug, Clone)]
+struct Options {
+    /// Per-test time budget for Node.js tests, in milliseconds; no budget
+    /// if unset.
+    max_test_ms: Option<u64>,
+    /// Debug server logs and verbose Bazel failures.
+    verbose: bool,
+    /// Rebuild openerpd from clean and compare binary hashes.
+    check_hermetic: bool,
+    /// Expunge Bazel outputs before the build step.
+    clean: bool,
+    /// Per-step profile to record, if any.
+    profile: Option<ProfileMode>,
+    /// Output directory for profiles; relative to the workspace root.
+    profile_dir: PathBuf,
+    /// First pipeline step to run (1-7).
+    step: u8,
+    /// Check external tools before running anything.
+    check_deps: bool,
+    /// Bazel executable.
+    bazel: PathBuf,
+    /// Artefact directory replacing e2e/test-results; relative to the
+    /// workspace root.
+    report_dir: Option<PathBuf>,
+    /// How runner messages are written.
+    log_format: LogFormat,
+    /// Least severe runner message written.
+    log_level: LogLevel,
+    /// Run only the smoke subset of tests and skip the timing gate.
+    smoke_only: bool,
+}
+
+/// What `--profile` records for each step.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum ProfileMode {
+    /// Wall-clock and CPU time (runner + reaped children).
+    Cpu,
+    /// Current and peak resident memory of the runner.
+    Mem,
+}
+
+impl std::str::FromStr for ProfileMode {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, String> {
+        match s {
+            "cpu" => Ok(ProfileMode::Cpu),
+            "mem" => Ok(ProfileMode::Mem),
+            other => Err(format!("--profile: expected cpu or mem, got {other:?}")),
+        }
+    }
+}
+
+impl Default for Options {
+    fn default() -> Self {
+        Self {
+            max_test_ms: None,
+            verbose: false,
+            check_hermetic: false,
+            clean: false,
+            profile: None,
+            profile_dir: PathBuf::from("e2e/test-results/profile"),
+            step: 1,
+            check_deps: false,
+            bazel: PathBuf::from("bazel"),
+            report_dir: None,
+            log_format: LogFormat::Text,
+            log_level: LogLevel::Info,
+            smoke_only: false,
+        }
+    }
+}
+
+impl Options {
+    /// Parse options from command-line arguments.
+    ///
+    /// Accepts both `--flag value` and `--flag=value`.
+    fn from_args(args: &[String]) -> Result<Self, String> {
+        let mut opts = Options::default();
+        let mut profile_dir_set = false;
+        let mut iter = args.iter();
+        while let Some(arg) = iter.next() {
+            let (flag, inline) = match arg.split_once('=') {
+                Some((f, v)) => (f, Some(v.to_string())),
+                None => (arg.as_str(), None),
+            };
+            let mut value = || {
+                inline
+                    .clone()
+                    .or_else(|| iter.next().cloned())
+                    .ok_or_else(|| format!("{flag} requires a value"))
+            };
+            match flag {
+                "--max-test-ms" => {
+                    opts.max_test_ms = Some(
+                        value()?
+                            .parse()
+                            .map_err(|e| format!("--max-test-ms: {e}"))?,
+                    );
+                }
+                "--verbose" if inline.is_none() => opts.verbose = true,
+                "--check-hermetic" if inline.is_none() => opts.check_hermetic = true,
+                "--clean" if inline.is_none() => opts.clean = true,
+                "--check-deps" if inline.is_none() => opts.check_deps = true,
+                "--smoke-only" if inline.is_none() => opts.smoke_only = true,
+                "--profile" => opts.profile = Some(value()?.parse()?),
+                "--profile-dir" => {
+                    opts.profile_dir = PathBuf::from(value()?);
+                    profile_dir_set = true;
+                }
+                "--bazel" => opts.bazel = PathBuf::from(value()?),
+                "--report-dir" => opts.report_dir = Some(PathBuf::from(value()?)),
+                "--log-format" => opts.log_format = value()?.parse()?,
+                "--log-level" => opts.log_level = value()?.parse()?,
+                "--step" => {
+                    opts.step = value()?.parse().map_err(|e| format!("--step: {e}"))?;
+                    if !(1..=7).contains(&opts.step) {
+                        return Err(format!("--step: expected 1-7, got {}", opts.step));
+                    }
+                }
+                other => return Err(format!("unknown flag: {other}")),
+            }
+        }
+        if let (Some(dir), false) = (&opts.report_dir, profile_dir_set) {
+            opts.profile_dir = dir.join("profile");
+        }
+        if opts.smoke_only && opts.check_hermetic {
+            return Err("--smoke-only and --check-hermetic cannot be combined".into());
+        }
+        Ok(opts)
+    }
+
+    /// Rust test targets for step 2.
+    fn rust_test_targets(&self) -> &'static [&'static str] {
+        if self.smoke_only {
+            SMOKE_RUST_TEST_TARGETS
+        } else {
+            RUST_TEST_TARGETS
+        }
+    }
+
+    /// Node.js test files for step 6.
+    fn e2e_test_files(&self) -> &'static [&'static str] {
+        if self.smoke_only {
+            SMOKE_TEST_FILES
+        } else {
+            E2E_TEST_FILES
+        }
+    }
+}
+
+// ── Logging ──
+
+/// `--log-format` of runner messages.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum LogFormat {
+    /// `level=INFO msg="..." key=value`, for people.
+    Text,
+    /// One JSON object per line, for log aggregation.
+    Json,
+}
+
+impl std::str::FromStr for LogFormat {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, String> {
+        match s {
+            "text" => Ok(LogFormat::Text),
+            "json" => Ok(LogFormat::Json),
+            other => Err(format!("--log-format: expected text or json, got {other:?}")),
+        }
+    }
+}
+
+/// Severity of a runner message, least severe first.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord)]
+enum LogLevel {
+    Debug,
+    Info,
+    Warn,
+    Error,
+}
+
+impl LogLevel {
+    fn as_str(self) -> &'static str {
+        match self {
+            LogLevel::Debug => "DEBUG",
+            LogLevel::Info => "INFO",
+            LogLevel::Warn => "WARN",
+            LogLevel::Error => "ERROR",
+        }
+    }
+}
+
+impl std::str::FromStr for LogLevel {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, String> {
+        match s {
+            "debug" => Ok(LogLevel::Debug),
+            "info" => Ok(LogLevel::Info),
+            "warn" => Ok(LogLevel::Warn),
+            "error" => Ok(LogLevel::Error),
+            other => Err(format!(
+                "--log-level: expected debug, info, warn or error, got {other:?}"
+            )),
+        }
+    }
+}
+
+/// Formats runner messages; see `log!`.
+#[derive(Debug, Clone, Copy)]
+struct Logger {
+    format: LogFormat,
+    level: LogLevel,
+}
+
+/// Set from the options once they parse; text at info until then.
+static LOGGER: OnceLock<Logger> = OnceLock::new();
+
+impl Logger {
+    /// One output line for a message, or `None` if it is below the level.
+    /// `time_ms` (Unix epoch) is only written in JSON.
+    fn format(
+        &self,
+        level: LogLevel,
+        msg: &str,
+        fields: &[(&str, serde_json::Value)],
+        time_ms: u128,
+    ) -> Option<String> {
+        if level < self.level {
+            return None;
+        }
+        match self.format {
+            LogFormat::Json => {
+                let mut obj = serde_json::Map::new();
+                obj.insert("time".into(), serde_json::json!(time_ms as u64));
+                obj.insert("level".into(), level.as_str().into());
+                obj.insert("msg".into(), msg.into());
+                for (key, value) in fields {
+                    obj.insert(key.to_string(), value.clone());
+                }
+                Some(serde_json::Value::Object(obj).to_string())
+            }
+            LogFormat::Text => {
+                let mut line = format!("level={} msg={}", level.as_str(), logfmt_value(msg));
+                for (key, value) in fields {
+                    let value = match value {
+                        serde_json::Value::String(s) => logfmt_value(s),
+                        other => other.to_string(),
+                    };
+                    line.push_str(&format!(" {key}={value}"));
+                }
+                Some(line)
+            }
+        }
+    }
+}
+
+/// A logfmt value: bare if it is a single plain token, quoted otherwise.
+fn logfmt_value(s: &str) -> String {
+    if s.is_empty() || s.contains(|c: char| c.is_whitespace() || c == '"' || c == '=') {
+        format!("{s:?}")
+    } else {
+        s.to_string()
+    }
+}
+
+/// Write one message to stderr through `LOGGER`.
+fn emit(level: LogLevel, msg: &str, fields: &[(&str, serde_json::Value)]) {
+    let logger = LOGGER.get_or_init(|| Logger { format: LogFormat::Text, level: LogLevel::Info });
+    let time_ms = std::time::SystemTime::now()
+        .duration_since(std::time::UNIX_EPOCH)
+        .map_or(0, |d| d.as_millis());
+    if let Some(line) = logger.format(level, msg, fields, time_ms) {
+        eprintln!("{line}");
+    }
+}
+
+/// `log!(level, msg, key = value, ...)`; values are anything `Serialize`.
+macro_rules! log {
+    ($level:expr, $msg:expr $(, $key:ident = $value:expr)* $(,)?) => {
+        emit($level, &$msg, &[$((stringify!($key), serde_json::json!($value))),*])
+    };
+}
+macro_rules! debug { ($($t:tt)*) => { log!(LogLevel::Debug, $($t)*) } }
+macro_rules! info { ($($t:tt)*) => { log!(LogLevel::Info, $($t)*) } }
+macro_rules! warn { ($($t:tt)*) => { log!(LogLevel::Warn, $($t)*) } }
+macro_rules! error { ($($t:tt)*) => { log!(LogLevel::Error, $($t)*) } }
+
 fn main() {
-    let root = find_workspace_root().unwrap_or_else(|| {
-        fatal("Cannot find workspace root (MODULE.bazel). Run via: bazel run //e2e/runner");
+    let args: Vec<String> = std::env::args().skip(1).collect();
+    std::process::exit(run_with_exit_code(&args));
+}
+
+/// Run the whole pipeline and return the process exit code: 0 on success,
+/// the Node test run's own code if the E2E tests fail, and 1 if argument
+/// parsing or any other step fails.
+///
+/// Returning (rather than exiting from inside a step) lets `ServerGuard`
+/// and the temp dir drop, so a failing run doesn't leak openerpd.
+fn run_with_exit_code(args: &[String]) -> i32 {
+    exit_code_with(args, run_pipeline)
+}
+
+fn exit_code_with(args: &[String], pipeline: impl FnOnce(&Options) -> Result<(), Failure>) -> i32 {
+    let result = Options::from_args(args).map_err(Failure::from).and_then(|opts| {
+        // First parse wins; later calls (tests) keep that logger.
+        let _ = LOGGER.set(Logger { format: opts.log_format, level: opts.log_level });
+        pipeline(&opts)
     });
+    match result {
+        Ok(()) => 0,
+        Err(e) => {
+            error!("Run failed", error = e.message, exit_code = e.code);
+            e.code
+        }
+    }
+}
+
+/// Why a run failed, and the exit code to report it with.
+#[derive(Debug)]
+struct Failure {
+    message: String,
+    /// 1, or a failed child's own code where it means something to the
+    /// caller (Node's test runner: 1 for failing tests, 2+ for its own
+    /// errors).
+    code: i32,
+}
+
+impl Failure {
+    /// A child process that exited unsuccessfully. Keeps its exit code;
+    /// one killed by a signal reports 128 + the signal, like a shell.
+    fn from_status(what: &str, status: std::process::ExitStatus) -> Self {
+        #[cfg(unix)]
+        let signal = std::os::unix::process::ExitStatusExt::signal(&status);
+        #[cfg(not(unix))]
+        let signal: Option<i32> = None;
+        let code = status.code().or(signal.map(|s| 128 + s)).unwrap_or(1);
+        Self { message: format!("{what} ({status})"), code }
+    }
+}
+
+impl From<String> for Failure {
+    fn from(message: String) -> Self {
+        Self { message, code: 1 }
+    }
+}
+
+impl From<&str> for Failure {
+    fn from(message: &str) -> Self {
+        message.to_string().into()
+    }
+}
+
+fn run_pipeline(opts: &Options) -> Result<(), Failure> {
+    let root = find_workspace_root().ok_or(
+        "Cannot find workspace root (MODULE.bazel). Run via: bazel run //e2e/runner",
+    )?;
     // When launched by `bazel run`, cwd is the sandbox. Switch to real workspace.
-    std::env::set_current_dir(&root).expect("chdir to workspace");
-    println!("Workspace: {}", root.display());
+    std::env::set_current_dir(&root).map_err(|e| format!("chdir to workspace: {e}"))?;
+    info!("Workspace", path = &root);
+    let result = run_steps(opts, &root);
+    // Also on failure: that is the run CI most wants to archive.
+    if let Some(dir) = &opts.report_dir {
+        info!("Report dir", path = root.join(dir));
+    }
+    result
+}
+
+/// The pipeline proper, against the workspace at `root`.
+fn run_steps(opts: &Options, root: &Path) -> Result<(), Failure> {
+    let root = root.to_path_buf();
+    if opts.check_deps {
+        let problems = check_dependencies(&opts.bazel, &root);
+        for p in &problems {
+            error!("Dependency problem", problem = p);
+        }
+        if !problems.is_empty() {
+            return Err(format!("{} dependency problem(s)", problems.len()).into());
+        }
+        info!("Dependencies OK");
+    }
+    let results_dir = root.join(
+        opts.report_dir.as_deref().unwrap_or(Path::new("e2e/test-results")),
+    );
+    let mut profiler = StepProfiler::new(opts.profile, root.join(&opts.profile_dir));
+    let bep_dir = tempfile::tempdir().map_err(|e| format!("create BEP temp dir: {e}"))?;
+    let mut bep = Bep {
+        dir: bep_dir.path().to_path_buf(),
+        summary_file: results_dir.join("bep_summary.json"),
+        targets: BTreeMap::new(),
+    };
+
+    // Steps 3 and 4 only set up per-run temp state, so they are never skipped.
+    let runs = |step: u8| step >= opts.step || step == 3 || step == 4;
+    if opts.step > 1 {
+        warn!(
+            "Starting mid-pipeline; earlier steps are assumed done. \
+             The run may fail if their outputs are missing or stale.",
+            step = opts.step,
+        );
+    }
 
     // Step 1: Build binaries.
-    step("Step 1: Build binaries");
-    bazel(&root, &["build", "//rust/bin/openerpd", "//rust/bin/openerp"]);
+    if runs(1) {
+        check_bazel_version(&opts.bazel, &root)?;
+        profiler.step("Step 1: Build binaries");
+        if opts.clean {
+            bazel(opts, &root, &["clean", "--expunge"])?;
+        }
+        let build_args = ["build", "//rust/bin/openerpd", "//rust/bin/openerp"];
+        bep.bazel(opts, &root, 1, &build_args)?;
 
-    let ext = if cfg!(windows) { ".exe" } else { "" };
-    let openerpd = root.join(format!("bazel-bin/rust/bin/openerpd/openerpd{ext}"));
-    let openerp = root.join(format!("bazel-bin/rust/bin/openerp/openerp{ext}"));
+        if opts.check_hermetic {
+            profiler.step("Step 1b: Hermetic build check");
+            check_hermetic_build(opts, &root, "//rust/bin/openerpd")?;
+            // `bazel clean` dropped the other outputs; bring them back.
+            bazel(opts, &root, &build_args)?;
+        }
+    }
+
+    let openerpd = resolve_bazel_bin(&root, "//rust/bin/openerpd")?;
+    let openerp = resolve_bazel_bin(&root, "//rust/bin/openerp")?;
 
     // Step 2: Rust unit tests.
-    step("Step 2: Rust tests");
-    bazel(
-        &root,
-        &[
-            "test",
-            "//rust/lib/dsl/golden:golden_test",
-            "//rust/lib/dsl/store:store_test",
-            "//rust/lib/dsl/types:types_test",
-            "//rust/lib/dsl/macro_test:macro_test",
-            "//rust/lib/core:core_test",
-            "//rust/mod/auth:auth_test",
-            "//rust/mod/pms:pms_test",
-            "//rust/mod/task:task_test",
-        ],
-    );
-    println!("Rust tests passed.");
+    if runs(2) {
+        profiler.step("Step 2: Rust tests");
+        // A failing test's log (assert_eq! left/right included) goes to
+        // the console, not only to bazel-testlogs.
+        let mut test_args = vec!["test", "--test_output=errors"];
+        test_args.extend(opts.rust_test_targets());
+        let result = bep.bazel(opts, &root, 2, &test_args);
+        // Per-case results, also (especially) when a target failed.
+        let rust_results = results_dir.join("rust_tests.json");
+        write_rust_test_results(&root, opts.rust_test_targets(), &rust_results);
+        result?;
+        info!("Rust tests passed");
+    }
 
     // Step 3: Create test context via CLI.
-    step("Step 3: Create test context");
+    profiler.step("Step 3: Create test context");
     let tmp_dir = tempfile::tempdir().expect("create temp dir");
     let config_dir = tmp_dir.path().join("config");
     let data_dir = tmp_dir.path().join("data");
@@ -72,91 +572,149 @@ fn main() {
             "--password",
             ROOT_PASS,
         ],
-    );
+    )?;
 
     let server_config = config_dir.join("e2e-test.toml");
-    assert!(
-        server_config.exists(),
-        "Server config not found: {}",
-        server_config.display()
-    );
-    println!("Server config: {}", server_config.display());
+    if !server_config.exists() {
+        return Err(format!("Server config not found: {}", server_config.display()).into());
+    }
+    info!("Server config", path = &server_config);
 
     // Step 4: Start server on a random port.
-    step("Step 4: Start server");
+    profiler.step("Step 4: Start server");
     let port = free_port();
     let listen = format!("127.0.0.1:{port}");
     let base_url = format!("http://{listen}");
 
     let mut server = Command::new(&openerpd)
         .args(["-c", server_config.to_str().unwrap(), "--listen", &listen])
-        .env("RUST_LOG", "warn")
+        .env("RUST_LOG", if opts.verbose { "debug" } else { "warn" })
         .stdout(Stdio::piped())
         .stderr(Stdio::piped())
         .spawn()
-        .expect("start openerpd");
-
-    // Stream server stderr in a background thread so we can see logs.
-    let stderr = server.stderr.take().unwrap();
-    let log_thread = std::thread::spawn(move || {
-        let reader = BufReader::new(stderr);
-        for line in reader.lines() {
-            if let Ok(line) = line {
-                eprintln!("[openerpd] {line}");
-            }
+        .map_err(|e| format!("start openerpd: {e}"))?;
+
+    // Stream server output in background threads so we can see logs and
+    // neither pipe fills up and blocks the server; with --report-dir, keep
+    // a copy of both streams next to the other artefacts.
+    let log_file = match &opts.report_dir {
+        Some(_) => {
+            std::fs::create_dir_all(&results_dir)
+                .map_err(|e| format!("create {}: {e}", results_dir.display()))?;
+            let path = results_dir.join("openerpd.log");
+            let file = std::fs::File::create(&path)
+                .map_err(|e| format!("create {}: {e}", path.display()))?;
+            Some(Arc::new(Mutex::new(file)))
         }
-    });
+        None => None,
+    };
+    let stderr_thread = stream_server_output(server.stderr.take().unwrap(), log_file.clone());
+    let stdout_thread = stream_server_output(server.stdout.take().unwrap(), log_file);
 
     let _guard = ServerGuard {
         child: server,
-        _log_thread: log_thread,
+        _log_threads: [stderr_thread, stdout_thread],
     };
 
-    wait_for_health(&base_url, Duration::from_secs(30));
-    println!("Server running on {base_url}");
+    let health = wait_for_health_response(&base_url, Duration::from_secs(30))?;
+    info!(
+        "Server running",
+        url = &base_url,
+        // /health reports a version only; openerpd is not stamped with a commit.
+        version = health["version"].as_str().unwrap_or("unknown"),
+    );
 
-    // Step 5: Install E2E Node deps if needed.
-    step("Step 5: Install E2E deps");
+    // Step 5: Install E2E Node deps if needed (`npm ci` with a lockfile).
     let e2e_dir = root.join("e2e");
-    if !e2e_dir.join("node_modules").exists() {
-        let mut cmd = Command::new("npm");
-        cmd.arg("install")
-            .current_dir(&e2e_dir)
-            .env("PUPPETEER_SKIP_DOWNLOAD", "true");
-        let status = cmd.status().expect("npm install");
-        if !status.success() {
-            fatal("npm install failed");
+    if runs(5) {
+        profiler.step("Step 5: Install E2E deps");
+        if !e2e_dir.join("node_modules").exists() {
+            let npm_command = npm_install_command(&e2e_dir);
+            debug!("Run command", program = "npm", args = [npm_command]);
+            let mut cmd = Command::new("npm");
+            cmd.arg(npm_command)
+                .current_dir(&e2e_dir)
+                .env("PUPPETEER_SKIP_DOWNLOAD", "true");
+            let status = cmd.status().map_err(|e| format!("npm {npm_command}: {e}"))?;
+            if !status.success() {
+                return Err(format!("npm {npm_command} failed").into());
+            }
         }
     }
 
     // Step 6: Run E2E tests.
-    step("Step 6: Run E2E tests");
-    let test_files = [
-        "tests/01-login.test.mjs",
-        "tests/02-dashboard-crud.test.mjs",
-        "tests/03-api-auth.test.mjs",
-        "tests/04-user-login.test.mjs",
-        "tests/05-facet-api.test.mjs",
-        "tests/06-pms-actions.test.mjs",
-        "tests/07-task-actions.test.mjs",
-        "tests/08-put-edit-api.test.mjs",
-        "tests/09-user-password-login.test.mjs",
+    std::fs::create_dir_all(&results_dir).expect("create test-results dir");
+    let tap_file = results_dir.join("results.tap");
+    let tap_dest = format!("--test-reporter-destination={}", tap_file.display());
+    let junit_file = results_dir.join("results.xml");
+    let junit_dest = format!("--test-reporter-destination={}", junit_file.display());
+
+    // Human-readable (spec) output on stdout in every mode, TAP to a file
+    // for timing analysis, JUnit XML for CI test reports.
+    let mut args: Vec<&str> = vec![
+        "--test",
+        "--test-reporter=spec",
+        "--test-reporter-destination=stdout",
+        "--test-reporter=tap",
+        &tap_dest,
+        "--test-reporter=junit",
+        &junit_dest,
     ];
-    let mut args: Vec<&str> = vec!["--test"];
-    args.extend(test_files.iter());
-
-    let status = Command::new("node")
-        .args(&args)
-        .current_dir(&e2e_dir)
-        .env("BASE_URL", &base_url)
-        .env("ROOT_PASS", ROOT_PASS)
-        .status()
-        .expect("run node tests");
-    if !status.success() {
-        fatal("E2E tests failed");
+    args.extend(opts.e2e_test_files());
+
+    if runs(6) {
+        profiler.step("Step 6: Run E2E tests");
+        debug!("Run command", program = "node", args = &args);
+        let status = Command::new("node")
+            .args(&args)
+            .current_dir(&e2e_dir)
+            .env("BASE_URL", &base_url)
+            .env("ROOT_PASS", ROOT_PASS)
+            .env("OPENERP_BIN", &openerp)
+            .env("OPENERPD_BIN", &openerpd)
+            .env("SERVER_CONFIG", &server_config)
+            .status()
+            .map_err(|e| format!("run node tests: {e}"))?;
+        if !status.success() {
+            return Err(Failure::from_status("E2E tests failed", status));
+        }
     }
 
-    println!("\n=== All tests passed! ===");
+    if opts.smoke_only {
+        info!("Smoke tests passed");
+        return Ok(());
+    }
+
+    // Step 7: Per-test timings, gated with --max-test-ms.
+    profiler.step("Step 7: Check test timings");
+    let tap = std::fs::read_to_string(&tap_file)
+        .map_err(|e| format!("read {}: {e}", tap_file.display()))?;
+    let timings = parse_tap_timings(&tap);
+    let timing_file = results_dir.join("timing.json");
+    let json = serde_json::to_string_pretty(&timings).expect("serialize timings");
+    std::fs::write(&timing_file, json).expect("write timing.json");
+    info!("Timings", path = &timing_file);
+
+    if let Some(max_test_ms) = opts.max_test_ms {
+        let slow: Vec<&TestTiming> = timings
+            .iter()
+            .filter(|t| t.duration_ms > max_test_ms as f64)
+            .collect();
+        if !slow.is_empty() {
+            for t in &slow {
+                warn!("Slow test", test = &t.name, duration_ms = t.duration_ms.round());
+            }
+            return Err(format!(
+                "{} test(s) exceeded --max-test-ms={}",
+                slow.len(),
+                max_test_ms
+            )
+            .into());
+        }
+    }
+
+    info!("All tests passed");
+    Ok(())
 }
 
 // ── Helpers ──
@@ -181,29 +739,382 @@ fn find_workspace_root() -> Option<PathBuf> {
     }
 }
 
-fn step(name: &str) {
-    println!("\n=== {name} ===");
+/// Prints step banners and, with `--profile`, writes `<dir>/step-N.json`
+/// when each step ends (the last one on drop).
+struct StepProfiler {
+    mode: Option<ProfileMode>,
+    dir: PathBuf,
+    count: usize,
+    current: Option<StepStart>,
 }
 
-fn bazel(dir: &Path, args: &[&str]) {
-    let status = Command::new("bazel")
-        .args(args)
+struct StepStart {
+    index: usize,
+    name: String,
+    at: Instant,
+    cpu_ms: Option<u64>,
+}
+
+impl StepProfiler {
+    fn new(mode: Option<ProfileMode>, dir: PathBuf) -> Self {
+        Self { mode, dir, count: 0, current: None }
+    }
+
+    fn step(&mut self, name: &str) {
+        self.finish();
+        info!(name);
+        self.count += 1;
+        if self.mode.is_some() {
+            self.current = Some(StepStart {
+                index: self.count,
+                name: name.to_string(),
+                at: Instant::now(),
+                cpu_ms: cpu_time_ms(),
+            });
+        }
+    }
+
+    fn finish(&mut self) {
+        let (Some(mode), Some(start)) = (self.mode, self.current.take()) else {
+            return;
+        };
+        let wall_ms = start.at.elapsed().as_millis() as u64;
+        let profile = match mode {
+            ProfileMode::Cpu => serde_json::json!({
+                "step": start.index,
+                "name": start.name,
+                "wallMs": wall_ms,
+                "cpuMs": cpu_time_ms().zip(start.cpu_ms).map(|(end, begin)| end - begin),
+            }),
+            ProfileMode::Mem => {
+                let (rss, hwm) = memory_kb().unzip();
+                serde_json::json!({
+                    "step": start.index,
+                    "name": start.name,
+                    "wallMs": wall_ms,
+                    "vmRssKb": rss,
+                    "vmHwmKb": hwm,
+                })
+            }
+        };
+        let path = self.dir.join(format!("step-{}.json", start.index));
+        let written = std::fs::create_dir_all(&self.dir)
+            .and_then(|_| std::fs::write(&path, profile.to_string()));
+        if let Err(e) = written {
+            warn!("Write profile failed", path = &path, error = e.to_string());
+        }
+    }
+}
+
+impl Drop for StepProfiler {
+    fn drop(&mut self) {
+        self.finish();
+    }
+}
+
+/// User + system CPU of the runner and its reaped children, in ms.
+/// Linux only (`/proc/self/stat`); `None` elsewhere.
+fn cpu_time_ms() -> Option<u64> {
+    let stat = std::fs::read_to_string("/proc/self/stat").ok()?;
+    // Skip past "(comm)"; utime, stime, cutime, cstime are fields 14–17.
+    let rest = stat.rsplit_once(')')?.1;
+    let ticks: Vec<u64> = rest
+        .split_whitespace()
+        .skip(11)
+        .take(4)
+        .filter_map(|f| f.parse().ok())
+        .collect();
+    // USER_HZ is fixed at 100 for the /proc ABI.
+    (ticks.len() == 4).then(|| ticks.iter().sum::<u64>() * 10)
+}
+
+/// Current and peak RSS of the runner in KiB (`/proc/self/status`, Linux only).
+fn memory_kb() -> Option<(u64, u64)> {
+    let status = std::fs::read_to_string("/proc/self/status").ok()?;
+    let field = |key: &str| {
+        status
+            .lines()
+            .find_map(|l| l.strip_prefix(key))
+            .and_then(|v| v.trim().trim_end_matches("kB").trim().parse().ok())
+    };
+    Some((field("VmRSS:")?, field("VmHWM:")?))
+}
+
+/// Run `bazel <command> [--verbose_failures] <args...>`.
+fn bazel(opts: &Options, dir: &Path, args: &[&str]) -> Result<(), String> {
+    debug!("Run command", program = &opts.bazel, args = args);
+    let mut cmd = Command::new(&opts.bazel);
+    cmd.args(&args[..1]);
+    if opts.verbose {
+        cmd.arg("--verbose_failures");
+    }
+    let status = cmd
+        .args(&args[1..])
         .current_dir(dir)
         .status()
-        .expect("run bazel");
+        .map_err(|e| spawn_error(&opts.bazel, e))?;
     if !status.success() {
-        fatal(&format!("bazel {} failed", args.join(" ")));
+        return Err(format!("bazel {} failed", args.join(" ")));
+    }
+    Ok(())
+}
+
+/// Per-target results of this run's Bazel invocations, collected from
+/// their build event logs (`--build_event_json_file`).
+struct Bep {
+    /// Where the per-step event logs go.
+    dir: PathBuf,
+    /// Summary written after every invocation.
+    summary_file: PathBuf,
+    targets: BTreeMap<String, BepTarget>,
+}
+
+/// One target's entry in bep_summary.json.
+#[derive(Debug, Default, Clone, PartialEq, serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+struct BepTarget {
+    /// Whether the target built; absent if Bazel never got to it.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    built: Option<bool>,
+    /// Test overall status (`PASSED`, `FAILED`, `FLAKY`, ...).
+    #[serde(skip_serializing_if = "Option::is_none")]
+    test_status: Option<String>,
+    /// Total test run time across attempts.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    test_duration_ms: Option<f64>,
+}
+
+impl Bep {
+    /// Like `bazel`, logging build events for `step` and updating the
+    /// summary file — also when the command fails, which is when the
+    /// per-target detail matters.
+    fn bazel(&mut self, opts: &Options, dir: &Path, step: u8, args: &[&str]) -> Result<(), String> {
+        let log = self.dir.join(format!("step-{step}.json"));
+        let flag = format!("--build_event_json_file={}", log.display());
+        let mut full = args.to_vec();
+        full.insert(1, &flag);
+        let result = bazel(opts, dir, &full);
+
+        match std::fs::read_to_string(&log) {
+            Ok(events) => parse_bep(&events, &mut self.targets),
+            Err(e) => warn!("No build event log", path = &log, error = e.to_string()),
+        }
+        let written = self
+            .summary_file
+            .parent()
+            .map_or(Ok(()), std::fs::create_dir_all)
+            .and_then(|()| {
+                let json =
+                    serde_json::to_string_pretty(&self.targets).expect("serialize BEP summary");
+                std::fs::write(&self.summary_file, json)
+            });
+        if let Err(e) = written {
+            warn!("Write BEP summary failed", path = &self.summary_file, error = e.to_string());
+        }
+        result
+    }
+}
+
+/// Fold a JSON build event log (one event per line) into `targets`.
+/// Only `targetCompleted` and `testSummary` events are used; lines that
+/// aren't JSON (e.g. a log cut short by a crash) are skipped.
+fn parse_bep(events: &str, targets: &mut BTreeMap<String, BepTarget>) {
+    for line in events.lines() {
+        let Ok(ev) = serde_json::from_str::<serde_json::Value>(line) else {
+            continue;
+        };
+        let id = &ev["id"];
+        if let Some(label) = id["targetCompleted"]["label"].as_str() {
+            // Failed targets carry `completed.success: false`, skipped ones
+            // an `aborted` payload instead.
+            let built = ev["completed"]["success"].as_bool().unwrap_or(false);
+            targets.entry(label.to_string()).or_default().built = Some(built);
+        } else if let Some(label) = id["testSummary"]["label"].as_str() {
+            let summary = &ev["testSummary"];
+            let entry = targets.entry(label.to_string()).or_default();
+            entry.test_status = summary["overallStatus"].as_str().map(String::from);
+            // Newer Bazel reports a protobuf Duration ("1.5s"), older only
+            // the millisecond count (an int64, so a JSON string).
+            entry.test_duration_ms = summary["totalRunDuration"]
+                .as_str()
+                .and_then(|d| d.strip_suffix('s')?.parse::<f64>().ok())
+                .map(|secs| secs * 1000.0)
+                .or_else(|| {
+                    let ms = &summary["totalRunDurationMillis"];
+                    ms.as_str().and_then(|v| v.parse().ok()).or_else(|| ms.as_f64())
+                });
+        }
+    }
+}
+
+/// Why `program` could not be started; a missing binary says so plainly
+/// rather than as a bare "No such file or directory".
+fn spawn_error(program: &Path, e: std::io::Error) -> String {
+    if e.kind() == std::io::ErrorKind::NotFound {
+        format!("{} not found on PATH ({e})", program.display())
+    } else {
+        format!("run {}: {e}", program.display())
+    }
+}
+
+/// Fail early if `bazel --version` is older than the workspace's
+/// `.bazelversion`. Plain `bazel` (not bazelisk) ignores that file.
+fn check_bazel_version(bazel: &Path, root: &Path) -> Result<(), String> {
+    let pinned = std::fs::read_to_string(root.join(".bazelversion"))
+        .map_err(|e| format!("read .bazelversion: {e}"))?;
+    let min = parse_version(pinned.trim())
+        .ok_or_else(|| format!("bad .bazelversion: {:?}", pinned.trim()))?;
+
+    let out = Command::new(bazel)
+        .arg("--version")
+        .current_dir(root)
+        .output()
+        .map_err(|e| spawn_error(bazel, e))?;
+    let stdout = String::from_utf8_lossy(&out.stdout);
+    // "bazel 9.0.0" (or "bazel 9.0.0rc1"; pre-release suffixes are ignored).
+    let found = stdout
+        .trim()
+        .strip_prefix("bazel ")
+        .and_then(parse_version)
+        .ok_or_else(|| format!("unrecognized bazel --version output: {:?}", stdout.trim()))?;
+    if found < min {
+        return Err(format!(
+            "bazel {} is older than {} required by .bazelversion",
+            stdout.trim().trim_start_matches("bazel "),
+            pinned.trim()
+        ));
+    }
+    Ok(())
+}
+
+/// Check every external tool the pipeline runs: `bazel` against
+/// `.bazelversion`, `node` against `MIN_NODE`, and that `npm` exists.
+/// Returns one message per problem, each with an install hint.
+fn check_dependencies(bazel: &Path, root: &Path) -> Vec<String> {
+    let mut problems = Vec::new();
+    if let Err(e) = check_bazel_version(bazel, root) {
+        problems.push(format!(
+            "{e}; install bazelisk (https://github.com/bazelbuild/bazelisk), \
+             which honors .bazelversion"
+        ));
+    }
+    let tools = [
+        ("node", Some(MIN_NODE), "install Node.js LTS from https://nodejs.org"),
+        ("npm", None, "npm ships with Node.js; reinstall Node.js"),
+    ];
+    for (tool, min, hint) in tools {
+        match tool_version(tool) {
+            Err(e) => problems.push(format!("{e}; {hint}")),
+            Ok(found) => {
+                if let Some(min) = min.filter(|&min| found < min) {
+                    problems.push(format!(
+                        "{tool} {}.{}.{} is older than {}.{}.{}; {hint}",
+                        found.0, found.1, found.2, min.0, min.1, min.2
+                    ));
+                }
+            }
+        }
+    }
+    problems
+}
+
+/// Version reported by `<tool> --version`, e.g. `v20.11.1` or `10.2.4`.
+fn tool_version(tool: &str) -> Result<(u32, u32, u32), String> {
+    let out = Command::new(tool)
+        .arg("--version")
+        .output()
+        .map_err(|e| format!("{tool} not found on PATH ({e})"))?;
+    let stdout = String::from_utf8_lossy(&out.stdout);
+    parse_version(stdout.trim().trim_start_matches('v'))
+        .ok_or_else(|| format!("unrecognized {tool} --version output: {:?}", stdout.trim()))
+}
+
+/// Parse `MAJOR[.MINOR[.PATCH]]`, ignoring any non-digit suffix on the
+/// last component; missing components are 0.
+fn parse_version(s: &str) -> Option<(u32, u32, u32)> {
+    let mut parts = [0u32; 3];
+    for (i, part) in s.split('.').take(3).enumerate() {
+        let digits: String = part.chars().take_while(|c| c.is_ascii_digit()).collect();
+        parts[i] = digits.parse().ok()?;
     }
+    Some((parts[0], parts[1], parts[2]))
 }
 
-fn run(bin: &Path, args: &[&str]) {
+/// Build `target` twice from a clean output tree with the remote cache off
+/// and compare the SHA-256 of the produced binary.
+fn check_hermetic_build(opts: &Options, root: &Path, target: &str) -> Result<(), String> {
+    use sha2::{Digest, Sha256};
+
+    let bin = root.join("bazel-bin").join(bazel_bin_path(target));
+    let mut hashes = Vec::new();
+    for _ in 0..2 {
+        bazel(opts, root, &["clean"])?;
+        bazel(opts, root, &["build", "--noremote_cache", target])?;
+        let bytes = std::fs::read(&bin).map_err(|e| format!("read {}: {e}", bin.display()))?;
+        hashes.push(format!("{:x}", Sha256::digest(&bytes)));
+    }
+    if hashes[0] != hashes[1] {
+        return Err(format!(
+            "{target} is not hermetic: sha256 {} != {}",
+            hashes[0], hashes[1]
+        ));
+    }
+    info!("Hermetic build", target = target, sha256 = &hashes[0]);
+    Ok(())
+}
+
+/// Output path of a binary target relative to bazel-bin:
+/// `//a/b` → `a/b/b`, `//a/b:c` → `a/b/c`.
+fn bazel_bin_path(target: &str) -> PathBuf {
+    let label = target.trim_start_matches("//");
+    let (pkg, name) = match label.split_once(':') {
+        Some((pkg, name)) => (pkg, name),
+        None => (label, label.rsplit('/').next().unwrap_or(label)),
+    };
+    let ext = if cfg!(windows) { ".exe" } else { "" };
+    PathBuf::from(pkg).join(format!("{name}{ext}"))
+}
+
+/// Real path of a built binary target. `bazel-bin` is usually a symlink
+/// into the output base; resolve it so a dangling link or a file that
+/// isn't executable fails here with a clear message, not at spawn time.
+fn resolve_bazel_bin(root: &Path, target: &str) -> Result<PathBuf, String> {
+    let link = root.join("bazel-bin").join(bazel_bin_path(target));
+    let path = std::fs::canonicalize(&link)
+        .map_err(|e| format!("{target}: resolve {}: {e}", link.display()))?;
+    let meta = std::fs::metadata(&path).map_err(|e| format!("{target}: {}: {e}", path.display()))?;
+    if !meta.is_file() {
+        return Err(format!("{target}: {} is not a file", path.display()));
+    }
+    #[cfg(unix)]
+    {
+        use std::os::unix::fs::PermissionsExt;
+        if meta.permissions().mode() & 0o111 == 0 {
+            return Err(format!("{target}: {} is not executable", path.display()));
+        }
+    }
+    Ok(path)
+}
+
+fn run(bin: &Path, args: &[&str]) -> Result<(), String> {
+    debug!("Run command", program = bin, args = args);
     let status = Command::new(bin)
         .args(args)
         .status()
-        .unwrap_or_else(|e| fatal(&format!("run {}: {e}", bin.display())));
+        .map_err(|e| format!("run {}: {e}", bin.display()))?;
     if !status.success() {
-        fatal(&format!("{} failed", bin.display()));
+        return Err(format!("{} failed", bin.display()));
     }
+    Ok(())
+}
+
+/// `ci` when `dir` has a lockfile: exact, reproducible versions and
+/// faster than `install`, which may also rewrite the lockfile.
+fn npm_install_command(dir: &Path) -> &'static str {
+    let locked = ["package-lock.json", "npm-shrinkwrap.json"]
+        .iter()
+        .any(|f| dir.join(f).is_file());
+    if locked { "ci" } else { "install" }
 }
 
 fn free_port() -> u16 {
@@ -211,32 +1122,211 @@ fn free_port() -> u16 {
     listener.local_addr().unwrap().port()
 }
 
-fn wait_for_health(base_url: &str, timeout: Duration) {
+/// Poll /health until it returns 200, then return the parsed JSON body.
+fn wait_for_health_response(
+    base_url: &str,
+    timeout: Duration,
+) -> Result<serde_json::Value, String> {
     let url = format!("{base_url}/health");
     let deadline = Instant::now() + timeout;
     while Instant::now() < deadline {
         if let Ok(resp) = reqwest::blocking::get(&url) {
             if resp.status().is_success() {
-                return;
+                return resp
+                    .json()
+                    .map_err(|e| format!("parse /health body: {e}"));
             }
         }
         std::thread::sleep(Duration::from_millis(500));
     }
-    fatal(&format!(
+    Err(format!(
         "Server did not become healthy within {}s",
         timeout.as_secs()
-    ));
+    ))
+}
+
+/// One Rust test case's outcome, from a libtest `test.log`.
+#[derive(Debug, Clone, PartialEq, serde::Serialize)]
+struct RustTestCase {
+    /// Module path of the test, e.g. `jwt::tests::expired_token`.
+    name: String,
+    /// `ok`, `FAILED` or `ignored`.
+    outcome: String,
+    /// For a failed case, its captured output: the panic message, with
+    /// the left/right values of a failed `assert_eq!`.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    failure: Option<String>,
 }
 
-fn fatal(msg: &str) -> ! {
-    eprintln!("FATAL: {msg}");
-    std::process::exit(1);
+/// Test cases reported by libtest in a Bazel `test.log`: the
+/// `test <name> ... <outcome>` lines, plus the `---- <name> stdout ----`
+/// section of each failed one. Everything else (the summary, Bazel's
+/// banner) is skipped.
+fn parse_test_log(log: &str) -> Vec<RustTestCase> {
+    let mut cases: Vec<RustTestCase> = log
+        .lines()
+        .filter_map(|line| {
+            let (name, outcome) = line.strip_prefix("test ")?.split_once(" ... ")?;
+            // "ignored, <reason>" when #[ignore = "..."] gives one.
+            let outcome = outcome.split(',').next()?.trim();
+            matches!(outcome, "ok" | "FAILED" | "ignored").then(|| RustTestCase {
+                name: name.to_string(),
+                outcome: outcome.to_string(),
+                failure: None,
+            })
+        })
+        .collect();
+
+    // A section runs to the next header, the closing list of failed
+    // names, or the summary.
+    let mut section: Option<(String, Vec<&str>)> = None;
+    let mut sections = Vec::new();
+    for line in log.lines() {
+        let header = line
+            .strip_prefix("---- ")
+            .and_then(|rest| rest.strip_suffix(" stdout ----"));
+        let end = header.is_some() || line == "failures:" || line.starts_with("test result: ");
+        if end {
+            sections.extend(section.take());
+        }
+        if let Some(name) = header {
+            section = Some((name.to_string(), Vec::new()));
+        } else if let Some((_, lines)) = section.as_mut() {
+            lines.push(line);
+        }
+    }
+    sections.extend(section);
+    for (name, lines) in sections {
+        if let Some(case) = cases.iter_mut().find(|c| c.name == name && c.outcome == "FAILED") {
+            case.failure = Some(lines.join("\n").trim().to_string());
+        }
+    }
+    cases
+}
+
+/// Log of a test target relative to bazel-testlogs:
+/// `//a/b:c_test` → `a/b/c_test/test.log`.
+fn bazel_testlog_path(target: &str) -> PathBuf {
+    let label = target.trim_start_matches("//");
+    let (pkg, name) = match label.split_once(':') {
+        Some((pkg, name)) => (pkg, name),
+        None => (label, label.rsplit('/').next().unwrap_or(label)),
+    };
+    PathBuf::from(pkg).join(name).join("test.log")
+}
+
+/// Parse the test.log of each of `targets` into `file` (target → cases)
+/// and log each failed case. A target without a log, e.g. one Bazel never
+/// got to, is left out.
+fn write_rust_test_results(root: &Path, targets: &[&str], file: &Path) {
+    let mut results = BTreeMap::new();
+    for target in targets {
+        let log = root.join("bazel-testlogs").join(bazel_testlog_path(target));
+        let Ok(text) = std::fs::read_to_string(&log) else {
+            debug!("No test log", target = target, path = &log);
+            continue;
+        };
+        let cases = parse_test_log(&text);
+        for case in cases.iter().filter(|c| c.outcome == "FAILED") {
+            error!(
+                "Rust test failed",
+                target = target,
+                test = &case.name,
+                log = &log,
+                output = &case.failure,
+            );
+        }
+        results.insert(target.to_string(), cases);
+    }
+    let written = file
+        .parent()
+        .map_or(Ok(()), std::fs::create_dir_all)
+        .and_then(|()| {
+            let json = serde_json::to_string_pretty(&results).expect("serialize Rust test results");
+            std::fs::write(file, json)
+        });
+    if let Err(e) = written {
+        warn!("Write Rust test results failed", path = file, error = e.to_string());
+    }
+}
+
+/// Duration of a single (leaf) Node.js test.
+#[derive(Debug, Clone, PartialEq, serde::Serialize)]
+#[serde(rename_all = "camelCase")]
+struct TestTiming {
+    /// Suite path + test name, joined with " > ".
+    name: String,
+    duration_ms: f64,
+    ok: bool,
+}
+
+/// Extract leaf test durations from Node's TAP reporter output.
+///
+/// Suites report the sum of their children, so only tests without
+/// subtests are returned. A result line has children iff the previous
+/// result line is nested deeper.
+fn parse_tap_timings(tap: &str) -> Vec<TestTiming> {
+    let mut timings = Vec::new();
+    let mut path: Vec<String> = Vec::new();
+    let mut prev_depth: Option<usize> = None;
+    let mut pending: Option<TestTiming> = None;
+
+    for line in tap.lines() {
+        let trimmed = line.trim_start();
+        let depth = (line.len() - trimmed.len()) / 4;
+
+        if let Some(name) = trimmed.strip_prefix("# Subtest: ") {
+            path.truncate(depth);
+            path.push(name.to_string());
+            continue;
+        }
+
+        let result = trimmed
+            .strip_prefix("ok ")
+            .map(|r| (true, r))
+            .or_else(|| trimmed.strip_prefix("not ok ").map(|r| (false, r)));
+        if let Some((ok, _)) = result {
+            let is_leaf = prev_depth.map_or(true, |d| d <= depth);
+            prev_depth = Some(depth);
+            pending = is_leaf.then(|| TestTiming {
+                name: path[..(depth + 1).min(path.len())].join(" > "),
+                duration_ms: 0.0,
+                ok,
+            });
+            continue;
+        }
+
+        if let Some(ms) = trimmed.strip_prefix("duration_ms: ") {
+            if let Some(mut t) = pending.take() {
+                t.duration_ms = ms.parse().unwrap_or(0.0);
+                timings.push(t);
+            }
+        }
+    }
+    timings
+}
+
+/// Log each line of one openerpd output stream, copying it to `log_file`
+/// if given. Lines from stdout and stderr share the file, whole lines at a
+/// time.
+fn stream_server_output(
+    stream: impl Read + Send + 'static,
+    log_file: Option<Arc<Mutex<std::fs::File>>>,
+) -> std::thread::JoinHandle<()> {
+    std::thread::spawn(move || {
+        for line in BufReader::new(stream).lines().map_while(Result::ok) {
+            info!(line, source = "openerpd");
+            if let Some(f) = &log_file {
+                let _ = writeln!(f.lock().unwrap(), "{line}");
+            }
+        }
+    })
 }
 
 /// RAII guard — kills the server process on drop.
 struct ServerGuard {
     child: Child,
-    _log_thread: std::thread::JoinHandle<()>,
+    _log_threads: [std::thread::JoinHandle<()>; 2],
 }
 
 impl Drop for ServerGuard {
@@ -245,3 +1335,511 @@ impl Drop for ServerGuard {
         let _ = self.child.wait();
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn args(list: &[&str]) -> Vec<String> {
+        list.iter().map(|s| s.to_string()).collect()
+    }
+
+    #[test]
+    fn options_defaults() {
+        let opts = Options::from_args(&[]).unwrap();
+        assert_eq!(opts.max_test_ms, None);
+        assert!(!opts.verbose);
+        assert_eq!(opts.step, 1);
+    }
+
+    #[test]
+    fn options_verbose() {
+        let opts = Options::from_args(&args(&["--verbose", "--max-test-ms=100"])).unwrap();
+        assert!(opts.verbose);
+        assert_eq!(opts.max_test_ms, Some(100));
+        assert!(Options::from_args(&args(&["--verbose=yes"])).is_err());
+    }
+
+    #[test]
+    fn options_check_hermetic() {
+        assert!(Options::from_args(&args(&["--check-hermetic"])).unwrap().check_hermetic);
+        assert!(!Options::from_args(&[]).unwrap().check_hermetic);
+    }
+
+    #[test]
+    fn exit_code_reflects_pipeline_result() {
+        assert_eq!(exit_code_with(&[], |_| Ok(())), 0);
+        assert_eq!(exit_code_with(&[], |_| Err("bazel build failed".into())), 1);
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn exit_code_carries_failed_child_code() {
+        let child = |script: &str| Command::new("sh").args(["-c", script]).status().unwrap();
+
+        // What `node --test` reports for failing tests, and for its own errors.
+        for code in [1, 2, 9] {
+            let status = child(&format!("exit {code}"));
+            let failure = Failure::from_status("E2E tests failed", status);
+            assert!(failure.message.starts_with("E2E tests failed"), "{}", failure.message);
+            assert_eq!(exit_code_with(&[], |_| Err(failure)), code);
+        }
+
+        // Killed by a signal: 128 + SIGKILL, as a shell would report it.
+        let status = child("kill -9 $$");
+        assert_eq!(exit_code_with(&[], |_| Err(Failure::from_status("E2E tests failed", status))), 137);
+    }
+
+    /// A stand-in Bazel in `root` that reports `version` and exits 1 for
+    /// every other command.
+    #[cfg(unix)]
+    fn fake_bazel(root: &Path, version: &str) -> PathBuf {
+        use std::os::unix::fs::PermissionsExt;
+        let bin = root.join("fake-bazel");
+        let script = format!(
+            "#!/bin/sh\nif [ \"$1\" = --version ]; then echo 'bazel {version}'; exit 0; fi\nexit 1\n"
+        );
+        std::fs::write(&bin, script).unwrap();
+        std::fs::set_permissions(&bin, std::fs::Permissions::from_mode(0o755)).unwrap();
+        bin
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn failing_build_exits_nonzero() {
+        let root = tempfile::tempdir().unwrap();
+        std::fs::write(root.path().join(".bazelversion"), "9.0.0\n").unwrap();
+
+        let bin = fake_bazel(root.path(), "9.0.0");
+        let argv = args(&["--bazel", bin.to_str().unwrap()]);
+        let opts = Options::from_args(&argv).unwrap();
+        let err = run_steps(&opts, root.path()).unwrap_err().message;
+        assert!(err.starts_with("bazel build"), "{err}");
+        assert_eq!(exit_code_with(&argv, |opts| run_steps(opts, root.path())), 1);
+
+        // An old Bazel fails the version check before building.
+        let bin = fake_bazel(root.path(), "8.1.0");
+        let opts = Options::from_args(&args(&["--bazel", bin.to_str().unwrap()])).unwrap();
+        let err = run_steps(&opts, root.path()).unwrap_err().message;
+        assert!(err.contains("older than 9.0.0"), "{err}");
+    }
+
+    #[test]
+    fn exit_code_on_bad_args_skips_pipeline() {
+        let mut ran = false;
+        let code = exit_code_with(&args(&["--bogus"]), |_| {
+            ran = true;
+            Ok(())
+        });
+        assert_eq!(code, 1);
+        assert!(!ran);
+        assert_eq!(run_with_exit_code(&args(&["--max-test-ms"])), 1);
+    }
+
+    #[test]
+    fn options_profile() {
+        let opts = Options::from_args(&args(&["--profile", "cpu"])).unwrap();
+        assert_eq!(opts.profile, Some(ProfileMode::Cpu));
+        assert_eq!(opts.profile_dir, PathBuf::from("e2e/test-results/profile"));
+        let opts = Options::from_args(&args(&["--profile=mem", "--profile-dir=/tmp/p"])).unwrap();
+        assert_eq!(opts.profile, Some(ProfileMode::Mem));
+        assert_eq!(opts.profile_dir, PathBuf::from("/tmp/p"));
+        assert!(Options::from_args(&args(&["--profile", "gpu"])).is_err());
+        assert_eq!(Options::from_args(&[]).unwrap().profile, None);
+    }
+
+    #[test]
+    fn options_report_dir() {
+        assert_eq!(Options::from_args(&[]).unwrap().report_dir, None);
+        let opts = Options::from_args(&args(&["--report-dir", "out/e2e"])).unwrap();
+        assert_eq!(opts.report_dir, Some(PathBuf::from("out/e2e")));
+        assert_eq!(opts.profile_dir, PathBuf::from("out/e2e/profile"));
+        // An explicit --profile-dir wins, in either order.
+        let argv = args(&["--profile-dir=/tmp/p", "--report-dir=out"]);
+        let opts = Options::from_args(&argv).unwrap();
+        assert_eq!(opts.profile_dir, PathBuf::from("/tmp/p"));
+    }
+
+    #[test]
+    fn options_logging() {
+        let opts = Options::from_args(&[]).unwrap();
+        assert_eq!((opts.log_format, opts.log_level), (LogFormat::Text, LogLevel::Info));
+        let argv = args(&["--log-format=json", "--log-level", "warn"]);
+        let opts = Options::from_args(&argv).unwrap();
+        assert_eq!((opts.log_format, opts.log_level), (LogFormat::Json, LogLevel::Warn));
+        assert!(Options::from_args(&args(&["--log-format", "xml"])).is_err());
+        assert!(Options::from_args(&args(&["--log-level=trace"])).is_err());
+    }
+
+    #[test]
+    fn logger_formats() {
+        let fields = [
+            ("path", serde_json::json!("/tmp/a b")),
+            ("duration_ms", serde_json::json!(12.5)),
+            ("step", serde_json::json!(2)),
+        ];
+        let text = Logger { format: LogFormat::Text, level: LogLevel::Info };
+        assert_eq!(
+            text.format(LogLevel::Warn, "Slow test", &fields, 0).unwrap(),
+            r#"level=WARN msg="Slow test" path="/tmp/a b" duration_ms=12.5 step=2"#
+        );
+        assert_eq!(
+            text.format(LogLevel::Info, "Timings", &[("path", "/tmp/t.json".into())], 0).unwrap(),
+            "level=INFO msg=Timings path=/tmp/t.json"
+        );
+        assert_eq!(text.format(LogLevel::Debug, "hidden", &[], 0), None);
+
+        let json = Logger { format: LogFormat::Json, level: LogLevel::Debug };
+        let line = json.format(LogLevel::Debug, "Run command", &fields, 1700000000123).unwrap();
+        let v: serde_json::Value = serde_json::from_str(&line).unwrap();
+        assert_eq!(v["time"], 1700000000123u64);
+        assert_eq!(v["level"], "DEBUG");
+        assert_eq!(v["msg"], "Run command");
+        assert_eq!(v["path"], "/tmp/a b");
+        assert_eq!(v["step"], 2);
+
+        let errors_only = Logger { format: LogFormat::Json, level: LogLevel::Error };
+        assert_eq!(errors_only.format(LogLevel::Warn, "x", &[], 0), None);
+        assert!(errors_only.format(LogLevel::Error, "x", &[], 0).is_some());
+    }
+
+    #[test]
+    fn profiler_writes_one_file_per_step() {
+        let dir = tempfile::tempdir().unwrap();
+        {
+            let mut profiler = StepProfiler::new(Some(ProfileMode::Cpu), dir.path().to_path_buf());
+            profiler.step("one");
+            profiler.step("two");
+        }
+        let one: serde_json::Value =
+            serde_json::from_str(&std::fs::read_to_string(dir.path().join("step-1.json")).unwrap())
+                .unwrap();
+        assert_eq!(one["name"], "one");
+        assert!(one["wallMs"].is_u64());
+        assert!(dir.path().join("step-2.json").exists(), "last step written on drop");
+
+        let off = tempfile::tempdir().unwrap();
+        StepProfiler::new(None, off.path().to_path_buf()).step("x");
+        assert_eq!(std::fs::read_dir(off.path()).unwrap().count(), 0);
+    }
+
+    #[test]
+    fn server_output_from_both_streams_lands_in_log_file() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("openerpd.log");
+        let file = Arc::new(Mutex::new(std::fs::File::create(&path).unwrap()));
+        let stderr = stream_server_output(&b"startup warning\n"[..], Some(file.clone()));
+        let stdout = stream_server_output(&b"GET /health 200\nGET /version 200\n"[..], Some(file));
+        stderr.join().unwrap();
+        stdout.join().unwrap();
+
+        let log = std::fs::read_to_string(&path).unwrap();
+        let mut lines: Vec<&str> = log.lines().collect();
+        lines.sort();
+        assert_eq!(lines, ["GET /health 200", "GET /version 200", "startup warning"]);
+    }
+
+    #[test]
+    fn npm_ci_only_with_lockfile() {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("package.json"), "{}").unwrap();
+        assert_eq!(npm_install_command(dir.path()), "install");
+        std::fs::write(dir.path().join("package-lock.json"), "{}").unwrap();
+        assert_eq!(npm_install_command(dir.path()), "ci");
+
+        let shrinkwrap = tempfile::tempdir().unwrap();
+        std::fs::write(shrinkwrap.path().join("npm-shrinkwrap.json"), "{}").unwrap();
+        assert_eq!(npm_install_command(shrinkwrap.path()), "ci");
+    }
+
+    #[test]
+    fn bazel_bin_paths() {
+        let ext = if cfg!(windows) { ".exe" } else { "" };
+        assert_eq!(
+            bazel_bin_path("//rust/bin/openerpd"),
+            PathBuf::from(format!("rust/bin/openerpd/openerpd{ext}"))
+        );
+        assert_eq!(
+            bazel_bin_path("//e2e/runner:runner"),
+            PathBuf::from(format!("e2e/runner/runner{ext}"))
+        );
+    }
+
+    #[cfg(unix)]
+    #[test]
+    fn resolve_bazel_bin_follows_symlink() {
+        use std::os::unix::fs::PermissionsExt;
+
+        let root = tempfile::tempdir().unwrap();
+        let out = root.path().join("out");
+        std::fs::create_dir_all(out.join("a/b")).unwrap();
+        std::os::unix::fs::symlink(&out, root.path().join("bazel-bin")).unwrap();
+
+        let err = resolve_bazel_bin(root.path(), "//a/b").unwrap_err();
+        assert!(err.contains("resolve"), "{err}");
+
+        let bin = out.join("a/b/b");
+        std::fs::write(&bin, "").unwrap();
+        std::fs::set_permissions(&bin, std::fs::Permissions::from_mode(0o644)).unwrap();
+        let err = resolve_bazel_bin(root.path(), "//a/b").unwrap_err();
+        assert!(err.contains("not executable"), "{err}");
+
+        std::fs::set_permissions(&bin, std::fs::Permissions::from_mode(0o755)).unwrap();
+        let resolved = resolve_bazel_bin(root.path(), "//a/b").unwrap();
+        assert_eq!(resolved, std::fs::canonicalize(&bin).unwrap());
+    }
+
+    #[test]
+    fn parse_versions() {
+        assert_eq!(parse_version("9.0.0"), Some((9, 0, 0)));
+        assert_eq!(parse_version("8.4"), Some((8, 4, 0)));
+        assert_eq!(parse_version("9.1.0rc2"), Some((9, 1, 0)));
+        assert_eq!(parse_version("no_version"), None);
+        assert!(parse_version("8.4.2") < parse_version("9.0.0"));
+        assert!(parse_version("10.0.0") > parse_version("9.9.9"));
+    }
+
+    #[test]
+    fn options_clean() {
+        assert!(!Options::from_args(&[]).unwrap().clean);
+        assert!(Options::from_args(&args(&["--clean"])).unwrap().clean);
+        assert!(Options::from_args(&args(&["--clean=yes"])).is_err());
+    }
+
+    #[test]
+    fn options_check_deps() {
+        assert!(!Options::from_args(&[]).unwrap().check_deps);
+        assert!(Options::from_args(&args(&["--check-deps"])).unwrap().check_deps);
+        assert!(Options::from_args(&args(&["--check-deps=1"])).is_err());
+    }
+
+    #[test]
+    fn options_smoke_only() {
+        let full = Options::from_args(&[]).unwrap();
+        assert!(!full.smoke_only);
+        assert_eq!(full.rust_test_targets(), RUST_TEST_TARGETS);
+        assert_eq!(full.e2e_test_files(), E2E_TEST_FILES);
+
+        let smoke = Options::from_args(&args(&["--smoke-only"])).unwrap();
+        assert_eq!(smoke.rust_test_targets(), ["//rust/lib/core:core_test"]);
+        assert_eq!(smoke.e2e_test_files(), ["tests/01-login.test.mjs"]);
+        // A subset, so smoke and full runs cannot drift apart.
+        assert!(smoke.rust_test_targets().iter().all(|t| RUST_TEST_TARGETS.contains(t)));
+        assert!(smoke.e2e_test_files().iter().all(|f| E2E_TEST_FILES.contains(f)));
+
+        assert!(Options::from_args(&args(&["--smoke-only=1"])).is_err());
+        assert!(Options::from_args(&args(&["--smoke-only", "--check-hermetic"])).is_err());
+    }
+
+    #[test]
+    fn missing_bazel_is_reported() {
+        // A bare name is looked up on PATH, like the default `bazel`.
+        let root = tempfile::tempdir().unwrap();
+        std::fs::write(root.path().join(".bazelversion"), "9.0.0\n").unwrap();
+        let opts = Options::from_args(&args(&["--bazel", "openerp-no-such-bazel"])).unwrap();
+
+        let err = bazel(&opts, root.path(), &["version"]).unwrap_err();
+        assert!(err.contains("openerp-no-such-bazel not found on PATH"), "{err}");
+        let err = check_bazel_version(&opts.bazel, root.path()).unwrap_err();
+        assert!(err.contains("not found on PATH"), "{err}");
+        let err = run_steps(&opts, root.path()).unwrap_err().message;
+        assert!(err.contains("not found on PATH"), "{err}");
+    }
+
+    #[test]
+    fn missing_tool_is_reported() {
+        let err = tool_version("openerp-no-such-tool").unwrap_err();
+        assert!(err.contains("not found on PATH"), "{err}");
+    }
+
+    #[test]
+    fn options_step() {
+        assert_eq!(Options::from_args(&args(&["--step", "4"])).unwrap().step, 4);
+        assert_eq!(Options::from_args(&args(&["--step=7"])).unwrap().step, 7);
+        assert!(Options::from_args(&args(&["--step", "0"])).is_err());
+        assert!(Options::from_args(&args(&["--step", "8"])).is_err());
+        assert!(Options::from_args(&args(&["--step", "x"])).is_err());
+    }
+
+    #[test]
+    fn options_max_test_ms() {
+        let opts = Options::from_args(&args(&["--max-test-ms", "250"])).unwrap();
+        assert_eq!(opts.max_test_ms, Some(250));
+        let opts = Options::from_args(&args(&["--max-test-ms=750"])).unwrap();
+        assert_eq!(opts.max_test_ms, Some(750));
+        assert!(Options::from_args(&args(&["--max-test-ms"])).is_err());
+        assert!(Options::from_args(&args(&["--bogus"])).is_err());
+    }
+
+    #[test]
+    fn bep_targets_and_tests() {
+        let events = r#"{"id":{"started":{}},"started":{"command":"test"}}
+{"id":{"targetCompleted":{"label":"//a:lib"}},"completed":{"success":true}}
+{"id":{"targetCompleted":{"label":"//a:a_test"}},"completed":{"success":true}}
+{"id":{"targetCompleted":{"label":"//b:bad"}},"completed":{}}
+{"id":{"targetCompleted":{"label":"//c:skipped"}},"aborted":{"reason":"SKIPPED"}}
+{"id":{"testSummary":{"label":"//a:a_test"}},"testSummary":{"overallStatus":"PASSED","totalRunDuration":"1.250s"}}
+{"id":{"testSummary":{"label":"//d:old_test"}},"testSummary":{"overallStatus":"FAILED","totalRunDurationMillis":"40"}}
+{"id":{"testSummary":{"label":"//e:trunc"#;
+        let mut targets = BTreeMap::new();
+        parse_bep(events, &mut targets);
+
+        let built = |b| BepTarget { built: Some(b), ..Default::default() };
+        assert_eq!(targets["//a:lib"], built(true));
+        assert_eq!(targets["//b:bad"], built(false));
+        assert_eq!(targets["//c:skipped"], built(false));
+        assert_eq!(
+            targets["//a:a_test"],
+            BepTarget {
+                built: Some(true),
+                test_status: Some("PASSED".into()),
+                test_duration_ms: Some(1250.0),
+            }
+        );
+        assert_eq!(
+            targets["//d:old_test"],
+            BepTarget {
+                built: None,
+                test_status: Some("FAILED".into()),
+                test_duration_ms: Some(40.0),
+            }
+        );
+        assert_eq!(targets.len(), 5);
+    }
+
+    #[test]
+    fn test_log_cases() {
+        let log = "\
+exec ${PAGER:-/usr/bin/less} \"$0\" || exit 1
+Executing tests from //rust/mod/auth:auth_test
+-----------------------------------------------------------------------------
+
+running 4 tests
+test jwt::tests::round_trip ... ok
+test jwt::tests::expired_token ... FAILED
+test store::tests::slow ... ignored, needs a network
+test password::tests::hash ... ok
+
+failures:
+
+---- jwt::tests::expired_token stdout ----
+test output with ... in it
+thread 'jwt::tests::expired_token' panicked at src/jwt.rs:10:5:
+assertion `left == right` failed
+
+test result: FAILED. 2 passed; 1 failed; 1 ignored; 0 measured; 0 filtered out
+";
+        let case = |name: &str, outcome: &str| RustTestCase {
+            name: name.into(),
+            outcome: outcome.into(),
+            failure: None,
+        };
+        assert_eq!(
+            parse_test_log(log),
+            vec![
+                case("jwt::tests::round_trip", "ok"),
+                RustTestCase {
+                    failure: Some(
+                        "test output with ... in it\n\
+                         thread 'jwt::tests::expired_token' panicked at src/jwt.rs:10:5:\n\
+                         assertion `left == right` failed"
+                            .into(),
+                    ),
+                    ..case("jwt::tests::expired_token", "FAILED")
+                },
+                case("store::tests::slow", "ignored"),
+                case("password::tests::hash", "ok"),
+            ]
+        );
+        assert_eq!(
+            bazel_testlog_path("//rust/mod/auth:auth_test"),
+            PathBuf::from("rust/mod/auth/auth_test/test.log")
+        );
+    }
+
+    #[test]
+    fn golden_failure_reported_with_diff() {
+        let root = tempfile::tempdir().unwrap();
+        let golden = "//rust/lib/dsl/golden:golden_test";
+        let log = root.path().join("bazel-testlogs").join(bazel_testlog_path(golden));
+        std::fs::create_dir_all(log.parent().unwrap()).unwrap();
+        std::fs::write(
+            &log,
+            "\
+running 2 tests
+test tests::golden_admin_group_by ... FAILED
+test tests::golden_admin_count ... ok
+
+failures:
+
+---- tests::golden_admin_group_by stdout ----
+
+thread 'tests::golden_admin_group_by' panicked at rust/lib/dsl/golden/src/lib.rs:1195:9:
+assertion `left == right` failed
+  left: Array [Object {\"count\": Number(2), \"value\": String(\"ok\")}]
+ right: Array [Object {\"count\": Number(3), \"value\": String(\"ok\")}]
+
+
+failures:
+    tests::golden_admin_group_by
+
+test result: FAILED. 1 passed; 1 failed; 0 ignored; 0 measured; 0 filtered out
+",
+        )
+        .unwrap();
+
+        let file = root.path().join("results/rust_tests.json");
+        write_rust_test_results(root.path(), &[golden, "//rust/lib/core:core_test"], &file);
+        let results: serde_json::Value =
+            serde_json::from_str(&std::fs::read_to_string(&file).unwrap()).unwrap();
+
+        // No log for core_test: left out rather than reported as passing.
+        assert_eq!(results.as_object().unwrap().len(), 1);
+        let cases = results[golden].as_array().unwrap();
+        assert_eq!(cases[1], serde_json::json!({"name": "tests::golden_admin_count", "outcome": "ok"}));
+        assert_eq!(cases[0]["outcome"], "FAILED");
+        let failure = cases[0]["failure"].as_str().unwrap();
+        assert!(failure.starts_with("thread 'tests::golden_admin_group_by' panicked"), "{failure}");
+        assert!(failure.contains("  left: Array"), "{failure}");
+        assert!(failure.ends_with("String(\"ok\")}]"), "failed names not included: {failure}");
+    }
+
+    #[test]
+    fn tap_timings_only_leaves() {
+        let tap = "\
+TAP version 13
+# Subtest: Suite
+    # Subtest: a
+    ok 1 - a
+      ---
+      duration_ms: 12.5
+      ...
+    # Subtest: b
+    not ok 2 - b
+      ---
+      duration_ms: 7000
+      ...
+    1..2
+ok 1 - Suite
+  ---
+  duration_ms: 7020.1
+  type: 'suite'
+  ...
+# Subtest: top
+ok 2 - top
+  ---
+  duration_ms: 3
+  ...
+1..2
+";
+        let timings = parse_tap_timings(tap);
+        assert_eq!(
+            timings,
+            vec![
+                TestTiming { name: "Suite > a".into(), duration_ms: 12.5, ok: true },
+                TestTiming { name: "Suite > b".into(), duration_ms: 7000.0, ok: false },
+                TestTiming { name: "top".into(), duration_ms: 3.0, ok: true },
+            ]
+        );
+    }
+}
@@ -0,0 +1,118 @@
+/**
+ * openerp / openerpd process helpers shared by the E2E suites.
+ *
+ * Used by both e2e/tests (through helpers.mjs) and the browser suite, so
+ * it imports Node built-ins only: each suite installs its own npm deps.
+ */
+
+import { spawn, spawnSync } from 'node:child_process';
+import { createHmac } from 'node:crypto';
+import { readFileSync } from 'node:fs';
+import { createServer } from 'node:net';
+import { join } from 'node:path';
+
+export const OPENERP_BIN = process.env.OPENERP_BIN || 'openerp';
+export const OPENERPD_BIN = process.env.OPENERPD_BIN || 'openerpd';
+const ROOT_PASS = process.env.ROOT_PASS || 'openerp123';
+
+/**
+ * Run the `openerp` CLI synchronously, returning exit status and output.
+ */
+export function runCli(args) {
+  const res = spawnSync(OPENERP_BIN, args, { encoding: 'utf8' });
+  if (res.error) throw res.error;
+  return { status: res.status, stdout: res.stdout, stderr: res.stderr };
+}
+
+/**
+ * Create a context under `dir` via `openerp context create`.
+ * `opts` may override `configDir`, `dataDir` and `password`. Returns the
+ * CLI result plus the expected server config and data-dir paths.
+ */
+export function createContext(dir, name, opts = {}) {
+  const configDir = opts.configDir || join(dir, 'config');
+  const dataDir = opts.dataDir || join(dir, 'data', name);
+  const res = runCli([
+    '--config', join(dir, 'client.toml'),
+    'context', 'create', name,
+    '--config-dir', configDir,
+    '--data-dir', dataDir,
+    '--password', opts.password || ROOT_PASS,
+  ]);
+  return { ...res, configPath: join(configDir, `${name}.toml`), dataDir };
+}
+
+/**
+ * Pick a free TCP port on localhost.
+ */
+export async function freePort() {
+  return new Promise((resolve, reject) => {
+    const srv = createServer();
+    srv.unref();
+    srv.on('error', reject);
+    srv.listen(0, '127.0.0.1', () => {
+      const { port } = srv.address();
+      srv.close(() => resolve(port));
+    });
+  });
+}
+
+/**
+ * Start a dedicated openerpd from a server config, with extra command-line
+ * flags from `opts.args`. Listens on `opts.port`, or a random free port.
+ * Waits for /health; returns `{ baseUrl, proc, stop, logs }` where
+ * `logs()` is the server's output so far (stderr and stdout, interleaved
+ * as they arrive).
+ */
+export async function startServer(configPath, opts = {}) {
+  const { rustLog = 'warn', maxRetries = 30, intervalMs = 500, args = [] } = opts;
+  const port = opts.port ?? await freePort();
+  const baseUrl = `http://127.0.0.1:${port}`;
+  const proc = spawn(OPENERPD_BIN, ['-c', configPath, '--listen', `127.0.0.1:${port}`, ...args], {
+    env: { ...process.env, RUST_LOG: rustLog },
+    stdio: ['ignore', 'pipe', 'pipe'],
+  });
+  let output = '';
+  proc.stdout.on('data', d => { output += d; });
+  proc.stderr.on('data', d => { output += d; });
+
+  const stop = async () => {
+    if (proc.exitCode !== null || proc.signalCode !== null) return;
+    const exited = new Promise(r => proc.once('exit', r));
+    proc.kill();
+    await exited;
+  };
+
+  for (let i = 0; i < maxRetries; i++) {
+    if (proc.exitCode !== null) {
+      throw new Error(`openerpd exited with ${proc.exitCode}: ${output}`);
+    }
+    try {
+      const resp = await fetch(`${baseUrl}/health`);
+      if (resp.ok) return { baseUrl, proc, stop, logs: () => output };
+    } catch {
+      // Server not ready yet.
+    }
+    await new Promise(r => setTimeout(r, intervalMs));
+  }
+  await stop();
+  throw new Error(`openerpd at ${baseUrl} not ready after ${maxRetries} retries: ${output}`);
+}
+
+/**
+ * Sign an HS256 JWT with the `[jwt] secret` in `configPath`. `claims` are
+ * merged over a one-hour, role-less "e2e" subject.
+ */
+export function signToken(claims, configPath) {
+  const m = readFileSync(configPath, 'utf8').match(/^secret\s*=\s*"([^"]*)"/m);
+  if (!m) throw new Error(`no [jwt] secret in ${configPath}`);
+
+  const now = Math.floor(Date.now() / 1000);
+  const b64 = (obj) => Buffer.from(JSON.stringify(obj)).toString('base64url');
+  const body = `${b64({ alg: 'HS256', typ: 'JWT' })}.${b64({
+    sub: 'e2e', name: 'E2E', groups: [], roles: [], sid: 'e2e', iat: now, exp: now + 3600,
+    ...claims,
+  })}`;
+  const sig = createHmac('sha256', m[1]).update(body).digest('base64url');
+  return `${body}.${sig}`;
+}
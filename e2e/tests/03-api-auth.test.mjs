@@ -6,6 +6,18 @@
  * 2. Authenticated requests succeed
  * 3. Expired/invalid tokens return 401
  * 4. Dashboard auto-redirects on 401
+ * 5. Login JWT payload carries sub/iat/exp
+ * 6. Concurrent logins each get a distinct token
+ * 7. The root user cannot be deleted, and root can still log in after
+ * 8. The root user is not a stored record and none may take its ID:
+ *    creating, PUTting or PATCHing "root" is 403, so its login and
+ *    password cannot be changed through the admin API, and tokens issued
+ *    before the attempt stay valid
+ * 9. Root's login name is its ID, and PATCHing it to "", to another
+ *    user's ID or to "newroot" is 403; root still logs in as before
+ * 10. There is no `GET /admin` index; /meta/schema is the (public)
+ *    discovery endpoint, and every resource it lists has a working admin
+ *    list path, auth/users included
  */
 
 import { describe, it, before, after } from 'node:test';
@@ -65,6 +77,32 @@ describe('API Authentication', () => {
     const version = await apiCall('GET', '/version');
     assert.equal(version.status, 200);
     assert.ok(version.data.name);
+
+    // Operators read the running version off /health; it must be a real
+    // semver and agree with /version.
+    assert.match(health.data.version, /^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$/);
+    assert.equal(health.data.version, version.data.version);
+  });
+
+  it('discovers admin resources through /meta/schema', async () => {
+    const index = await apiCall('GET', '/admin', null, token);
+    assert.equal(index.status, 404, 'no /admin index endpoint');
+
+    const schema = await apiCall('GET', '/meta/schema');
+    assert.equal(schema.status, 200);
+    // Same path rule as the dashboard: /admin/<module>/<plural resource>.
+    const paths = schema.data.modules.flatMap(m => m.resources.map(r => {
+      const snake = r.resource.replace(/[A-Z]/g, c => `_${c.toLowerCase()}`);
+      const plural = /(s|x|z|ch|sh)$/.test(snake) ? `${snake}es`
+        : /[^aeiou]y$/.test(snake) ? `${snake.slice(0, -1)}ies` : `${snake}s`;
+      return `${m.id}/${plural}`;
+    }));
+    assert.ok(paths.includes('auth/users'), `auth/users in ${paths}`);
+
+    for (const path of paths) {
+      const resp = await apiCall('GET', `/admin/${path}?limit=1`, null, token);
+      assert.equal(resp.status, 200, `GET /admin/${path}`);
+    }
   });
 
   it('login endpoint works and returns JWT', async () => {
@@ -78,6 +116,99 @@ describe('API Authentication', () => {
     assert.ok(resp.data.expires_in > 0);
   });
 
+  it('login JWT carries root claims', async () => {
+    const resp = await apiCall('POST', '/auth/login', {
+      username: ROOT_USER,
+      password: ROOT_PASS,
+    });
+    assert.equal(resp.status, 200);
+
+    // Decode the payload only; the signing key is server-side.
+    const [, payload] = resp.data.access_token.split('.');
+    const claims = JSON.parse(Buffer.from(payload, 'base64url').toString('utf8'));
+    const now = Math.floor(Date.now() / 1000);
+    assert.equal(claims.sub, 'root');
+    assert.ok(claims.exp > now, `exp ${claims.exp} should be in the future`);
+    assert.ok(claims.iat <= now, `iat ${claims.iat} should not be in the future`);
+  });
+
+  it('concurrent logins each get a distinct token', async () => {
+    const results = await Promise.all(Array.from({ length: 50 }, () =>
+      apiCall('POST', '/auth/login', { username: ROOT_USER, password: ROOT_PASS })));
+
+    const tokens = new Set();
+    for (const r of results) {
+      assert.equal(r.status, 200);
+      assert.ok(r.data.access_token, 'non-empty token');
+      tokens.add(r.data.access_token);
+    }
+    assert.equal(tokens.size, results.length, 'no duplicate tokens');
+  });
+
+  it('root user cannot be deleted', async () => {
+    const resp = await apiCall('DELETE', '/admin/auth/users/root', null, token);
+    assert.equal(resp.status, 403);
+    assert.equal(resp.data.code, 'PERMISSION_DENIED');
+    assert.ok(resp.data.message, 'error body has a message');
+
+    const login = await apiCall('POST', '/auth/login', {
+      username: ROOT_USER, password: ROOT_PASS,
+    });
+    assert.equal(login.status, 200, 'root can still log in');
+  });
+
+  it('root user cannot be modified through the admin API', async () => {
+    // Root lives in the server config, not the user store.
+    const attempts = [
+      ['POST with id root', 'POST', '/admin/auth/users', { id: 'root', email: 'newroot@test.com', active: true }],
+      ['PATCH new email', 'PATCH', '/admin/auth/users/root', { email: 'newroot@test.com' }],
+      ['PATCH password', 'PATCH', '/admin/auth/users/root', { passwordHash: 'x' }],
+      ['PUT', 'PUT', '/admin/auth/users/root', { id: 'root', active: true }],
+    ];
+    for (const [name, method, path, body] of attempts) {
+      const resp = await apiCall(method, path, body, token);
+      assert.equal(resp.status, 403, `${name}: status`);
+      assert.equal(resp.data.code, 'PERMISSION_DENIED', `${name}: code`);
+    }
+    const stored = await apiCall('GET', '/admin/auth/users/root', null, token);
+    assert.equal(stored.status, 404, 'no stored user took the root ID');
+
+    // The root password lives in the server config, so nothing changed
+    // and the token from before still works.
+    const list = await apiCall('GET', '/admin/auth/users', null, token);
+    assert.equal(list.status, 200, 'earlier token still valid');
+
+    const login = await apiCall('POST', '/auth/login', {
+      username: ROOT_USER, password: ROOT_PASS,
+    });
+    assert.equal(login.status, 200, 'root can still log in');
+  });
+
+  it('root login name cannot be changed', async () => {
+    const other = await apiCall('POST', '/admin/auth/users', {
+      displayName: 'E2E Root Rename', email: `e2e-rename-${Date.now().toString(36)}@test.com`, active: true,
+    }, token);
+    assert.equal(other.status, 200);
+
+    try {
+      const attempts = [['empty', ''], ['taken', other.data.id], ['new', 'newroot']];
+      for (const [name, id] of attempts) {
+        const resp = await apiCall('PATCH', '/admin/auth/users/root', { id }, token);
+        assert.equal(resp.status, 403, `${name}: status`);
+        assert.equal(resp.data.code, 'PERMISSION_DENIED', `${name}: code`);
+      }
+      const renamed = await apiCall('GET', '/admin/auth/users/newroot', null, token);
+      assert.equal(renamed.status, 404, 'no user was created as newroot');
+
+      const login = await apiCall('POST', '/auth/login', {
+        username: ROOT_USER, password: ROOT_PASS,
+      });
+      assert.equal(login.status, 200, 'root still logs in as root');
+    } finally {
+      await apiCall('DELETE', `/admin/auth/users/${other.data.id}`, null, token);
+    }
+  });
+
   it('login rejects wrong password', async () => {
     const resp = await apiCall('POST', '/auth/login', {
       username: ROOT_USER,
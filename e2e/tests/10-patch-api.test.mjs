@@ -0,0 +1,193 @@
+/**
+ * E2E Test: PATCH API
+ *
+ * Directly tests the admin PATCH (merge patch) endpoint:
+ * 1. PATCH on a deleted record returns 404 (not 500 / 409)
+ * 2. PATCH returns the complete merged record, not just the patched fields
+ * 3. `null` clears an optional field and reads back as `null`, not ""
+ * 4. Concurrent PATCHes carrying the same `updatedAt`: exactly one wins
+ * 5. An `updatedAt` that is not an RFC 3339 string, or a field of the wrong
+ *    type, is a 400 VALIDATION_FAILED (not a 409 or 500) and changes nothing
+ * 6. Zero-value `updatedAt` from an uninitialized client field: "" is a 400,
+ *    the Unix epoch a 409
+ * 7. Unknown fields are ignored, not rejected: the PATCH succeeds and the
+ *    field is neither returned nor stored (models don't deny unknown fields)
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert/strict';
+import {
+  ROOT_USER,
+  ROOT_PASS,
+  waitForServer,
+  apiCall,
+} from './helpers.mjs';
+
+describe('PATCH API', () => {
+  let rootToken;
+  const userIds = [];
+
+  before(async () => {
+    await waitForServer();
+    const resp = await apiCall('POST', '/auth/login', {
+      username: ROOT_USER, password: ROOT_PASS,
+    });
+    rootToken = resp.data.access_token;
+  });
+
+  after(async () => {
+    for (const id of userIds) {
+      await apiCall('DELETE', `/admin/auth/users/${id}`, null, rootToken);
+    }
+  });
+
+  async function createUser(extra = {}) {
+    const resp = await apiCall('POST', '/admin/auth/users', {
+      displayName: 'E2E Patch User', active: true, ...extra,
+    }, rootToken);
+    assert.equal(resp.status, 200);
+    userIds.push(resp.data.id);
+    return resp.data;
+  }
+
+  it('PATCH on a deleted record returns 404', async () => {
+    const user = await createUser();
+
+    const del = await apiCall('DELETE', `/admin/auth/users/${user.id}`, null, rootToken);
+    assert.equal(del.status, 204);
+
+    // The server must not try to merge into a missing row.
+    const patch = await apiCall('PATCH', `/admin/auth/users/${user.id}`, {
+      displayName: 'Ghost',
+    }, rootToken);
+    assert.equal(patch.status, 404, `Expected 404, got ${patch.status}`);
+    assert.equal(patch.data.code, 'NOT_FOUND');
+  });
+
+  it('PATCH returns the complete updated record', async () => {
+    const user = await createUser({ email: 'patch-full@test.com' });
+
+    const patch = await apiCall('PATCH', `/admin/auth/users/${user.id}`, {
+      displayName: 'E2E Patch Full',
+    }, rootToken);
+    assert.equal(patch.status, 200);
+
+    // Every field present on create must still be in the response.
+    for (const key of Object.keys(user)) {
+      assert.ok(key in patch.data, `PATCH response missing field '${key}'`);
+    }
+    assert.equal(patch.data.displayName, 'E2E Patch Full');
+    assert.equal(patch.data.id, user.id);
+    assert.equal(patch.data.email, 'patch-full@test.com', 'unpatched field kept');
+    assert.equal(patch.data.active, true, 'unpatched field kept');
+    assert.equal(patch.data.createdAt, user.createdAt, 'createdAt preserved');
+  });
+
+  it('PATCH with null clears an optional field', async () => {
+    const user = await createUser();
+
+    // Merge patch: null removes the value. updatedAt guards the write.
+    const patch = await apiCall('PATCH', `/admin/auth/users/${user.id}`, {
+      displayName: null, updatedAt: user.updatedAt,
+    }, rootToken);
+    assert.equal(patch.status, 200);
+    assert.ok('displayName' in patch.data, 'displayName key still present');
+    assert.strictEqual(patch.data.displayName, null);
+
+    const got = await apiCall('GET', `/admin/auth/users/${user.id}`, null, rootToken);
+    assert.equal(got.status, 200);
+    assert.strictEqual(got.data.displayName, null, 'null persisted');
+  });
+
+  it('concurrent PATCHes with the same updatedAt: exactly one wins', async () => {
+    const user = await createUser();
+    const ROUNDS = 5;
+    const WRITERS = 20;
+    let successes = 0;
+    let last = user;
+
+    for (let round = 0; round < ROUNDS; round++) {
+      const current = await apiCall('GET', `/admin/auth/users/${user.id}`, null, rootToken);
+      assert.equal(current.status, 200);
+
+      const results = await Promise.all(Array.from({ length: WRITERS }, (_, i) =>
+        apiCall('PATCH', `/admin/auth/users/${user.id}`, {
+          displayName: `E2E Race ${round}-${i}`, updatedAt: current.data.updatedAt,
+        }, rootToken)));
+
+      const ok = results.filter(r => r.status === 200);
+      assert.equal(ok.length, 1, `round ${round}: ${ok.length} PATCHes succeeded`);
+      for (const r of results.filter(r => r.status !== 200)) {
+        assert.equal(r.status, 409);
+        assert.equal(r.data.code, 'ALREADY_EXISTS');
+      }
+      successes += ok.length;
+      last = ok[0].data;
+    }
+
+    assert.equal(successes, ROUNDS);
+    const final = await apiCall('GET', `/admin/auth/users/${user.id}`, null, rootToken);
+    assert.equal(final.data.displayName, last.displayName, 'winning write persisted');
+    assert.equal(final.data.updatedAt, last.updatedAt);
+  });
+
+  it('PATCH with a malformed updatedAt or field type returns 400', async () => {
+    const user = await createUser();
+    // This repo locks on `updatedAt` rather than a numeric rev.
+    const cases = [
+      ['updatedAt not a date', { updatedAt: 'not-a-number', displayName: 'X' }],
+      ['updatedAt float', { updatedAt: 1.5, displayName: 'X' }],
+      ['updatedAt negative', { updatedAt: -1 }],
+      ['updatedAt null', { updatedAt: null, displayName: 'X' }],
+      ['displayName number', { displayName: 5 }],
+      ['active string', { active: 'yes' }],
+    ];
+    for (const [name, body] of cases) {
+      const resp = await apiCall('PATCH', `/admin/auth/users/${user.id}`, body, rootToken);
+      assert.equal(resp.status, 400, `${name}: status`);
+      assert.equal(resp.data.code, 'VALIDATION_FAILED', `${name}: code`);
+      assert.ok(resp.data.message, `${name}: message`);
+    }
+
+    const got = await apiCall('GET', `/admin/auth/users/${user.id}`, null, rootToken);
+    assert.equal(got.data.displayName, user.displayName, 'record unchanged');
+    assert.equal(got.data.updatedAt, user.updatedAt);
+  });
+
+  it('PATCH with a zero-value updatedAt is rejected', async () => {
+    const user = await createUser();
+    const path = `/admin/auth/users/${user.id}`;
+
+    // "" is not a timestamp at all: 400, like any malformed value.
+    const empty = await apiCall('PATCH', path, { updatedAt: '', displayName: 'X' }, rootToken);
+    assert.equal(empty.status, 400);
+    assert.equal(empty.data.code, 'VALIDATION_FAILED');
+
+    // The epoch is well-formed but no stored record has it: 409, like any
+    // stale updatedAt.
+    const epoch = await apiCall('PATCH', path, {
+      updatedAt: '1970-01-01T00:00:00Z', displayName: 'X',
+    }, rootToken);
+    assert.equal(epoch.status, 409);
+    assert.equal(epoch.data.code, 'ALREADY_EXISTS');
+
+    const got = await apiCall('GET', path, null, rootToken);
+    assert.equal(got.data.displayName, user.displayName, 'record unchanged');
+  });
+
+  it('PATCH ignores unknown fields', async () => {
+    const user = await createUser();
+    const path = `/admin/auth/users/${user.id}`;
+
+    const resp = await apiCall('PATCH', path, {
+      unknownField: 'value', displayName: 'E2E Patch Unknown', updatedAt: user.updatedAt,
+    }, rootToken);
+    assert.equal(resp.status, 200);
+    assert.equal(resp.data.displayName, 'E2E Patch Unknown', 'known field applied');
+    assert.ok(!('unknownField' in resp.data), 'unknown field not echoed');
+
+    const got = await apiCall('GET', path, null, rootToken);
+    assert.ok(!('unknownField' in got.data), 'unknown field not stored');
+    assert.equal(got.data.updatedAt, resp.data.updatedAt);
+  });
+});
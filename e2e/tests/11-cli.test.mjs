@@ -0,0 +1,266 @@
+/**
+ * E2E Test: openerp / openerpd command line
+ *
+ * Drives the CLI binaries directly (paths from OPENERP_BIN / OPENERPD_BIN):
+ * 1. `context create` creates a missing, nested data-dir, and writes the
+ *    server config directly into `--config-dir` with the root password
+ *    hashed, never in plaintext
+ * 2. Concurrent `context create` runs against one client config keep
+ *    every context
+ * 3. `--version` on both binaries prints a bare semver version (no `v`),
+ *    the same string a running openerpd reports on /version and /health,
+ *    and `--help` prints usage with each binary's main flags
+ * 4. `use context` switches the active context, and a server started from
+ *    the active context's config serves that context's data directory
+ * 5. `--config` picks the client config `context list` reads: an empty file
+ *    (/dev/null) lists no contexts, a populated one lists exactly its own
+ * 6. `context create` rejects names that are not a plain file stem (path
+ *    separators, `..`, spaces) before writing anything; dots are allowed
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert/strict';
+import { execFile, spawnSync } from 'node:child_process';
+import { existsSync, mkdtempSync, readFileSync, rmSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { dirname, join, resolve } from 'node:path';
+import { promisify } from 'node:util';
+import {
+  ROOT_USER,
+  ROOT_PASS,
+  OPENERP_BIN,
+  OPENERPD_BIN,
+  createContext,
+  runCli,
+  startServer,
+} from './helpers.mjs';
+
+const execFileAsync = promisify(execFile);
+
+/** Active context of a client config: `{ name, configPath }`. */
+function activeContext(clientConfig) {
+  const content = readFileSync(clientConfig, 'utf8');
+  const name = content.match(/^current-context = "(.*)"$/m)?.[1];
+  const block = content.split('[[contexts]]')
+    .find(b => b.match(/^name = "(.*)"$/m)?.[1] === name);
+  return { name, configPath: block?.match(/^config_path = "(.*)"$/m)?.[1] };
+}
+
+/** Log in as root on `baseUrl` and call `method path`; returns `{ status, data }`. */
+async function rootCall(baseUrl, method, path, body) {
+  const login = await fetch(`${baseUrl}/auth/login`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ username: ROOT_USER, password: ROOT_PASS }),
+  });
+  const { access_token: token } = await login.json();
+  const resp = await fetch(`${baseUrl}${path}`, {
+    method,
+    headers: { 'Content-Type': 'application/json', 'Authorization': `Bearer ${token}` },
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  return { status: resp.status, data: await resp.json() };
+}
+
+describe('CLI', () => {
+  let tmp;
+
+  before(() => {
+    tmp = mkdtempSync(join(tmpdir(), 'openerp-cli-'));
+  });
+
+  after(() => {
+    if (tmp) rmSync(tmp, { recursive: true, force: true });
+  });
+
+  it('context create makes a missing nested data-dir', async () => {
+    const dataDir = join(tmp, 'new', 'nested', 'path');
+    assert.ok(!existsSync(join(tmp, 'new')), 'precondition: path does not exist');
+
+    const ctx = createContext(tmp, 'nested', { dataDir });
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+    assert.ok(existsSync(dataDir), 'nested data-dir created');
+
+    // The server must start from the freshly created directory.
+    const server = await startServer(ctx.configPath);
+    try {
+      const resp = await fetch(`${server.baseUrl}/health`);
+      assert.equal(resp.status, 200);
+    } finally {
+      await server.stop();
+    }
+  });
+
+  it('context create writes the server config into --config-dir', () => {
+    const configDir = join(tmp, 'cfg', 'placed');
+    const ctx = createContext(tmp, 'placed', { configDir });
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+
+    // The CLI reports where it wrote the file; it must be configDir itself.
+    const reported = ctx.stdout.match(/Config:\s*(.+)/)?.[1]?.trim();
+    assert.ok(reported, `no Config: line in output:\n${ctx.stdout}`);
+    assert.equal(resolve(dirname(reported)), resolve(configDir));
+    assert.ok(existsSync(join(configDir, 'placed.toml')), 'config file in configDir');
+    assert.ok(!existsSync(join(process.cwd(), 'placed.toml')), 'not written to the cwd');
+  });
+
+  it('context create stores only a hash of the root password', () => {
+    const ctx = createContext(tmp, 'hashed');
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+
+    const config = readFileSync(ctx.configPath, 'utf8');
+    const hash = config.match(/^\[root\][^[]*?^password_hash = "(.*)"$/m)?.[1];
+    assert.ok(hash, `no [root] password_hash in:\n${config}`);
+    assert.match(hash, /^\$(argon2id|2b)\$/, 'argon2id or bcrypt hash');
+    assert.ok(!config.includes(ROOT_PASS), 'plaintext password not in config');
+  });
+
+  it('concurrent context create keeps every context', async () => {
+    const dir = join(tmp, 'concurrent');
+    const clientConfig = join(dir, 'client.toml');
+    const names = [1, 2, 3, 4, 5].map(i => `ctx-${i}`);
+
+    // Start all five at once; each does load → modify → save on client.toml.
+    const results = await Promise.allSettled(names.map(name => execFileAsync(OPENERP_BIN, [
+      '--config', clientConfig,
+      'context', 'create', name,
+      '--config-dir', join(dir, 'config'),
+      '--data-dir', join(dir, 'data', name),
+      '--password', ROOT_PASS,
+    ])));
+    for (const [i, r] of results.entries()) {
+      assert.equal(r.status, 'fulfilled', `${names[i]} failed: ${r.reason?.stderr}`);
+    }
+
+    const content = readFileSync(clientConfig, 'utf8');
+    for (const name of names) {
+      assert.match(content, new RegExp(`name = "${name}"`), `${name} missing from client config`);
+    }
+  });
+
+  it('--version prints a semver version', () => {
+    for (const bin of [OPENERP_BIN, OPENERPD_BIN]) {
+      const res = spawnSync(bin, ['--version'], { encoding: 'utf8' });
+      assert.equal(res.status, 0, `${bin} --version failed: ${res.stderr}`);
+      assert.match(res.stdout, /^\S+ \d+\.\d+\.\d+\S*\n$/, `${bin} --version output: ${res.stdout}`);
+    }
+  });
+
+  it('openerpd --version matches the running server', async () => {
+    const res = spawnSync(OPENERPD_BIN, ['--version'], { encoding: 'utf8' });
+    assert.equal(res.status, 0, `--version failed: ${res.stderr}`);
+    const cliVersion = res.stdout.match(/^openerpd (\d+\.\d+\.\d+\S*)$/m)?.[1];
+    assert.ok(cliVersion, `--version output: ${res.stdout}`);
+
+    const ctx = createContext(tmp, 'versioned');
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+    const server = await startServer(ctx.configPath);
+    try {
+      const version = await (await fetch(`${server.baseUrl}/version`)).json();
+      const health = await (await fetch(`${server.baseUrl}/health`)).json();
+      assert.equal(version.version, cliVersion, 'the binary and /version disagree');
+      assert.equal(health.version, cliVersion, 'the binary and /health disagree');
+    } finally {
+      await server.stop();
+    }
+  });
+
+  it('--help prints usage and exits 0', () => {
+    const expected = [
+      [OPENERPD_BIN, ['--config', '--listen']],
+      // The client has no server to listen on; its main entry is `context`.
+      [OPENERP_BIN, ['--config', 'context']],
+    ];
+    for (const [bin, flags] of expected) {
+      const res = spawnSync(bin, ['--help'], { encoding: 'utf8' });
+      assert.equal(res.status, 0, `${bin} --help failed: ${res.stderr}`);
+      const out = res.stdout + res.stderr;
+      assert.match(out, /Usage:|USAGE:/, `${bin} --help output: ${out}`);
+      for (const flag of flags) {
+        assert.ok(out.includes(flag), `${bin} --help lists ${flag}`);
+      }
+    }
+  });
+
+  it('context list reads the client config given by --config', () => {
+    const empty = runCli(['--config', '/dev/null', 'context', 'list']);
+    assert.equal(empty.status, 0, `list with /dev/null failed: ${empty.stderr}`);
+    assert.match(empty.stdout, /No contexts configured/);
+
+    const dir = join(tmp, 'listing');
+    for (const name of ['list-a', 'list-b']) {
+      const ctx = createContext(dir, name);
+      assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+    }
+    const listed = runCli(['--config', join(dir, 'client.toml'), 'context', 'list']);
+    assert.equal(listed.status, 0, `list failed: ${listed.stderr}`);
+    const rows = listed.stdout.split('\n').slice(1).filter(l => l.trim());
+    const names = rows.map(l => l.slice(2).trim().split(/\s+/)[0]).sort();
+    assert.deepEqual(names, ['list-a', 'list-b'], `unexpected listing:\n${listed.stdout}`);
+    assert.equal(rows.filter(l => l.startsWith('*')).length, 1, 'one active context');
+
+    // Another config file: none of the contexts above leak into it.
+    const other = runCli(['--config', join(tmp, 'listing-none.toml'), 'context', 'list']);
+    assert.equal(other.status, 0);
+    assert.match(other.stdout, /No contexts configured/);
+  });
+
+  it('context create rejects names that are not a plain file stem', () => {
+    const dir = join(tmp, 'names');
+    for (const name of ['test/slash', 'test space', '../escape', '..']) {
+      const ctx = createContext(dir, name);
+      assert.notEqual(ctx.status, 0, `${JSON.stringify(name)} was accepted`);
+      assert.match(ctx.stderr, /Invalid context name/, `${JSON.stringify(name)}: ${ctx.stderr}`);
+    }
+    assert.ok(!existsSync(join(dir, 'config')), 'nothing written for rejected names');
+    assert.ok(!existsSync(join(dir, 'escape.toml')), 'config path escaped --config-dir');
+
+    const dotted = createContext(dir, 'test.dot');
+    assert.equal(dotted.status, 0, `context create test.dot failed: ${dotted.stderr}`);
+    assert.ok(existsSync(dotted.configPath), 'test.dot.toml written');
+  });
+
+  it('use context switches the active context and its data', async () => {
+    const dir = join(tmp, 'switch');
+    const clientConfig = join(dir, 'client.toml');
+    for (const name of ['first', 'second']) {
+      const ctx = createContext(dir, name);
+      assert.equal(ctx.status, 0, `context create ${name} failed: ${ctx.stderr}`);
+    }
+    assert.equal(activeContext(clientConfig).name, 'first', 'first context is active by default');
+
+    const use = (name) => {
+      const res = runCli(['--config', clientConfig, 'use', 'context', name]);
+      assert.equal(res.status, 0, `use context ${name} failed: ${res.stderr}`);
+    };
+
+    // Write a record through a server started from the active (second) context.
+    use('second');
+    const second = activeContext(clientConfig);
+    assert.equal(second.name, 'second');
+    let server = await startServer(second.configPath);
+    try {
+      const created = await rootCall(server.baseUrl, 'POST', '/admin/auth/users', {
+        displayName: 'E2E Switch User', active: true,
+      });
+      assert.equal(created.status, 200);
+    } finally {
+      await server.stop();
+    }
+
+    // Back on the first context the record must not be visible.
+    use('first');
+    const first = activeContext(clientConfig);
+    assert.equal(first.name, 'first');
+    assert.notEqual(first.configPath, second.configPath);
+    server = await startServer(first.configPath);
+    try {
+      const list = await rootCall(server.baseUrl, 'GET', '/admin/auth/users?limit=1000');
+      assert.equal(list.status, 200);
+      assert.ok(!list.data.items.some(u => u.displayName === 'E2E Switch User'),
+        'first context does not see the second context\'s data');
+    } finally {
+      await server.stop();
+    }
+  });
+});
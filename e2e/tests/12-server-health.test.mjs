@@ -0,0 +1,266 @@
+/**
+ * E2E Test: Server health on a dedicated instance
+ *
+ * Each test starts its own openerpd from a fresh context so it can break
+ * the server's environment without affecting the shared test server:
+ * 1. /health reports 503 "degraded" when the data directory disappears,
+ *    and recovers once it is restored
+ * 2. The access log records the client IP of each request, and the trace
+ *    ID of a W3C `traceparent` header
+ * 3. With `[http] allowed_origins` set, CORS answers only those origins,
+ *    and preflights list only the API's methods and request headers
+ * 4. A config with only the required fields starts, and the optional ones
+ *    take their documented defaults (24h tokens, no CORS)
+ * 5. `--read-only` on a read-only (0555) data directory: the server starts,
+ *    login and GETs work, and POST / PATCH / PUT / DELETE get 403 READ_ONLY
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert/strict';
+import { appendFileSync, chmodSync, mkdtempSync, readFileSync, renameSync, rmSync, writeFileSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import {
+  ROOT_USER,
+  ROOT_PASS,
+  apiCall,
+  apiOptions,
+  createContext,
+  startServer,
+  traceHeaders,
+} from './helpers.mjs';
+
+/** Wait up to 2s for a server log line matching `pred`; returns it or undefined. */
+async function waitForLogLine(server, pred) {
+  // Access log lines are written after the response; allow a moment.
+  const deadline = Date.now() + 2000;
+  for (;;) {
+    const line = server.logs().split('\n').find(pred);
+    if (line || Date.now() >= deadline) return line;
+    await new Promise(r => setTimeout(r, 50));
+  }
+}
+
+describe('Server health (dedicated server)', () => {
+  let tmp;
+
+  before(() => {
+    tmp = mkdtempSync(join(tmpdir(), 'openerp-health-'));
+  });
+
+  after(() => {
+    if (tmp) rmSync(tmp, { recursive: true, force: true });
+  });
+
+  it('returns 503 while the data directory is unreachable', async () => {
+    const ctx = createContext(tmp, 'outage');
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+
+    const server = await startServer(ctx.configPath);
+    const moved = `${ctx.dataDir}.moved`;
+    try {
+      // Simulate a storage outage.
+      renameSync(ctx.dataDir, moved);
+      const down = await fetch(`${server.baseUrl}/health`);
+      assert.equal(down.status, 503);
+      const body = await down.json();
+      assert.equal(body.status, 'degraded');
+
+      // Restore and expect recovery within 10 seconds.
+      renameSync(moved, ctx.dataDir);
+      const deadline = Date.now() + 10_000;
+      let status;
+      while (Date.now() < deadline) {
+        status = (await fetch(`${server.baseUrl}/health`)).status;
+        if (status === 200) break;
+        await new Promise(r => setTimeout(r, 250));
+      }
+      assert.equal(status, 200, 'health recovered after restore');
+    } finally {
+      await server.stop();
+    }
+  });
+
+  it('access log contains the remote IP', async () => {
+    const ctx = createContext(tmp, 'access');
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+
+    const server = await startServer(ctx.configPath, { rustLog: 'warn,openerpd::access=info' });
+    try {
+      const resp = await fetch(`${server.baseUrl}/version`);
+      assert.equal(resp.status, 200);
+
+      const line = await waitForLogLine(server,
+        l => l.includes('/version') && l.includes('127.0.0.1'));
+      assert.ok(line, `no access log line with 127.0.0.1:\n${server.logs()}`);
+    } finally {
+      await server.stop();
+    }
+  });
+
+  it('access log carries the traceparent trace ID', async () => {
+    const ctx = createContext(tmp, 'trace');
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+
+    const server = await startServer(ctx.configPath, { rustLog: 'warn,openerpd::access=info' });
+    try {
+      const traceId = '4bf92f3577b34da6a3ce929d0e0e4736';
+      const resp = await apiCall('GET', '/version', null, null, {
+        baseUrl: server.baseUrl,
+        headers: traceHeaders(traceId, '00f067aa0ba902b7'),
+      });
+      assert.equal(resp.status, 200);
+
+      const line = await waitForLogLine(server,
+        l => l.includes('/version') && l.includes(`trace=${traceId}`));
+      assert.ok(line, `no access log line with trace=${traceId}:\n${server.logs()}`);
+    } finally {
+      await server.stop();
+    }
+  });
+
+  it('CORS allows only the configured origins', async () => {
+    const ctx = createContext(tmp, 'cors');
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+    appendFileSync(ctx.configPath, '\n[http]\nallowed_origins = ["http://trusted.example.com"]\n');
+
+    const server = await startServer(ctx.configPath);
+    const trusted = 'http://trusted.example.com';
+    const evil = 'http://evil.example.com';
+    try {
+      // Plain request: the origin is echoed only when allowed.
+      const plain = async (origin) => {
+        const resp = await fetch(`${server.baseUrl}/health`, { headers: { 'Origin': origin } });
+        await resp.arrayBuffer();
+        return resp.headers.get('access-control-allow-origin');
+      };
+      assert.equal(await plain(trusted), trusted);
+      assert.equal(await plain(evil), null);
+
+      // Preflight: `allow*` lists what the response must contain,
+      // `deny*` what it must not.
+      const cases = [
+        { name: 'trusted GET', origin: trusted, method: 'GET', allowOrigin: trusted, allowMethod: 'GET' },
+        {
+          name: 'trusted PATCH with auth headers', origin: trusted, method: 'PATCH',
+          requestHeaders: 'authorization,content-type',
+          allowOrigin: trusted, allowMethod: 'PATCH', allowHeaders: ['authorization', 'content-type'],
+        },
+        { name: 'trusted TRACE', origin: trusted, method: 'TRACE', allowOrigin: trusted, denyMethod: 'TRACE' },
+        {
+          name: 'trusted with custom header', origin: trusted, method: 'GET', requestHeaders: 'x-custom',
+          allowOrigin: trusted, denyHeader: 'x-custom',
+        },
+        { name: 'evil GET', origin: evil, method: 'GET', allowOrigin: null },
+        { name: 'evil DELETE', origin: evil, method: 'DELETE', allowOrigin: null },
+      ];
+      for (const c of cases) {
+        const h = await apiOptions('/admin/auth/users', c.origin, c.method, {
+          baseUrl: server.baseUrl, requestHeaders: c.requestHeaders,
+        });
+        const list = (name) => (h.get(name) ?? '').toLowerCase().split(',').map(v => v.trim());
+        assert.equal(h.get('access-control-allow-origin'), c.allowOrigin, `${c.name}: allow-origin`);
+        if (c.allowMethod) assert.ok(list('access-control-allow-methods').includes(c.allowMethod.toLowerCase()), `${c.name}: method allowed`);
+        if (c.denyMethod) assert.ok(!list('access-control-allow-methods').includes(c.denyMethod.toLowerCase()), `${c.name}: method not allowed`);
+        for (const name of c.allowHeaders ?? []) {
+          assert.ok(list('access-control-allow-headers').includes(name), `${c.name}: ${name} allowed`);
+        }
+        if (c.denyHeader) assert.ok(!list('access-control-allow-headers').includes(c.denyHeader), `${c.name}: header not allowed`);
+      }
+    } finally {
+      await server.stop();
+    }
+  });
+
+  it('starts from a minimal config with optional fields defaulted', async () => {
+    const ctx = createContext(tmp, 'minimal');
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+    // Keep only [root], [storage] and [jwt] with their required keys.
+    const minimal = readFileSync(ctx.configPath, 'utf8')
+      .split('\n')
+      .filter(l => !/^\s*(#|expire_secs\b)/.test(l))
+      .join('\n');
+    assert.doesNotMatch(minimal, /expire_secs|\[http\]/);
+    writeFileSync(ctx.configPath, minimal);
+
+    const server = await startServer(ctx.configPath);
+    try {
+      const health = await apiCall('GET', '/health', null, null, { baseUrl: server.baseUrl });
+      assert.equal(health.status, 200);
+      assert.equal(health.data.status, 'ok');
+
+      // jwt.expire_secs defaults to 86400.
+      const login = await apiCall('POST', '/auth/login', {
+        username: ROOT_USER, password: ROOT_PASS,
+      }, null, { baseUrl: server.baseUrl });
+      assert.equal(login.status, 200);
+      assert.equal(login.data.expires_in, 86400);
+      const [, payload] = login.data.access_token.split('.');
+      const claims = JSON.parse(Buffer.from(payload, 'base64url').toString('utf8'));
+      assert.equal(claims.exp - claims.iat, 86400);
+
+      // http.allowed_origins defaults to empty: no CORS headers at all.
+      const h = await apiOptions('/health', 'http://trusted.example.com', 'GET', {
+        baseUrl: server.baseUrl,
+      });
+      assert.equal(h.get('access-control-allow-origin'), null);
+    } finally {
+      await server.stop();
+    }
+  });
+
+  it('serves reads only with --read-only on a read-only data directory', async () => {
+    const ctx = createContext(tmp, 'readonly');
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+
+    // A normal run first creates the store and a record to read back.
+    let user;
+    const seed = await startServer(ctx.configPath);
+    try {
+      const login = await apiCall('POST', '/auth/login', {
+        username: ROOT_USER, password: ROOT_PASS,
+      }, null, { baseUrl: seed.baseUrl });
+      const created = await apiCall('POST', '/admin/auth/users', {
+        displayName: 'E2E Read Only', active: true,
+      }, login.data.access_token, { baseUrl: seed.baseUrl });
+      assert.equal(created.status, 200);
+      user = created.data;
+    } finally {
+      await seed.stop();
+    }
+
+    chmodSync(ctx.dataDir, 0o555);
+    const server = await startServer(ctx.configPath, { args: ['--read-only'] });
+    try {
+      const opts = { baseUrl: server.baseUrl };
+      const login = await apiCall('POST', '/auth/login', {
+        username: ROOT_USER, password: ROOT_PASS,
+      }, null, opts);
+      assert.equal(login.status, 200, 'login still works');
+      const token = login.data.access_token;
+
+      const got = await apiCall('GET', `/admin/auth/users/${user.id}`, null, token, opts);
+      assert.equal(got.status, 200);
+      assert.equal(got.data.displayName, 'E2E Read Only');
+      assert.equal((await apiCall('GET', '/admin/auth/users/@count', null, token, opts)).status, 200);
+
+      const path = `/admin/auth/users/${user.id}`;
+      const writes = [
+        ['POST', '/admin/auth/users', { displayName: 'E2E Read Only 2', active: true }],
+        ['PATCH', path, { displayName: 'X' }],
+        ['PUT', path, { ...user, displayName: 'X' }],
+        ['DELETE', path, null],
+      ];
+      for (const [method, p, body] of writes) {
+        const resp = await apiCall(method, p, body, token, opts);
+        assert.equal(resp.status, 403, `${method} ${p}: status`);
+        assert.equal(resp.data.code, 'READ_ONLY', `${method} ${p}: code`);
+      }
+      const unchanged = await apiCall('GET', path, null, token, opts);
+      assert.equal(unchanged.data.updatedAt, user.updatedAt, 'record unchanged');
+    } finally {
+      await server.stop();
+      chmodSync(ctx.dataDir, 0o755);
+    }
+  });
+});
@@ -0,0 +1,363 @@
+/**
+ * E2E Test: List pagination & @count API
+ *
+ * Tests:
+ * 1. Offset beyond the end returns 200 with empty items (not 404 / 416)
+ * 2. @count and the full list agree after a series of creates and deletes
+ * 3. @group_by buckets records by field value with correct counts
+ * 4. @count is behind the same authentication as the list
+ * 5. Keyset pagination with `after=<id>` continues in id order, no overlap
+ * 6. On a fresh server, listing everything returns [] and @count is 0
+ * 7. @count read concurrently with 100 creates never decreases and ends
+ *    at exactly +100 (fresh server)
+ * 8. @count and the list read during 100 create+delete cycles only ever see
+ *    0 or 1 records, never a deleted record or a negative count (fresh server)
+ * 9. Per-value counts: @group_by on `active` agrees with @count and with the
+ *    list filtered client-side (fresh server)
+ * 10. @group_by pages a 200-value field: `limit=50` gives exactly 50 buckets
+ *    per page, following `offset` until `hasMore` clears accounts for all
+ *    200 values with none repeated across pages (fresh server)
+ * 11. apiListAll against a broken server that always answers `hasMore`
+ *    gives up with an error at its 10 000 item limit instead of hanging
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert/strict';
+import { createServer } from 'node:http';
+import { mkdtempSync, rmSync } from 'node:fs';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
+import {
+  ROOT_USER,
+  ROOT_PASS,
+  waitForServer,
+  apiCall,
+  apiListAll,
+  createContext,
+  startServer,
+} from './helpers.mjs';
+
+/**
+ * Run `fn({ baseUrl, token })` against a fresh openerpd with its own data
+ * directory, for tests that need exact counts the shared server can't give.
+ */
+async function withFreshServer(name, fn) {
+  const tmp = mkdtempSync(join(tmpdir(), `openerp-${name}-`));
+  try {
+    const ctx = createContext(tmp, name);
+    assert.equal(ctx.status, 0, `context create failed: ${ctx.stderr}`);
+    const server = await startServer(ctx.configPath);
+    try {
+      const login = await apiCall('POST', '/auth/login', {
+        username: ROOT_USER, password: ROOT_PASS,
+      }, null, { baseUrl: server.baseUrl });
+      assert.equal(login.status, 200);
+      await fn({ baseUrl: server.baseUrl, token: login.data.access_token });
+    } finally {
+      await server.stop();
+    }
+  } finally {
+    rmSync(tmp, { recursive: true, force: true });
+  }
+}
+
+describe('List & @count API', () => {
+  let rootToken;
+  const userIds = [];
+  const groupIds = [];
+
+  before(async () => {
+    await waitForServer();
+    const resp = await apiCall('POST', '/auth/login', {
+      username: ROOT_USER, password: ROOT_PASS,
+    });
+    rootToken = resp.data.access_token;
+  });
+
+  after(async () => {
+    for (const id of userIds) {
+      await apiCall('DELETE', `/admin/auth/users/${id}`, null, rootToken);
+    }
+    for (const id of groupIds) {
+      await apiCall('DELETE', `/admin/auth/groups/${id}`, null, rootToken);
+    }
+  });
+
+  it('offset beyond the end returns empty items', async () => {
+    const resp = await apiCall('GET', '/admin/auth/users?limit=10&offset=99999', null, rootToken);
+    assert.equal(resp.status, 200);
+    assert.deepEqual(resp.data.items, []);
+    assert.equal(resp.data.hasMore, false);
+  });
+
+  // Uses groups: no other test file touches them, so concurrently running
+  // files cannot shift the counts.
+  it('@count matches the list after creates and deletes', async () => {
+    const count = async () => {
+      const resp = await apiCall('GET', '/admin/auth/groups/@count', null, rootToken);
+      assert.equal(resp.status, 200);
+      return resp.data.count;
+    };
+    const baseline = await count();
+
+    const created = [];
+    for (let i = 0; i < 5; i++) {
+      const resp = await apiCall('POST', '/admin/auth/groups', {
+        displayName: `E2E Count Group ${i}`,
+      }, rootToken);
+      assert.equal(resp.status, 200);
+      created.push(resp.data.id);
+      groupIds.push(resp.data.id);
+    }
+    assert.equal(await count(), baseline + 5);
+
+    for (const id of created.slice(0, 3)) {
+      const del = await apiCall('DELETE', `/admin/auth/groups/${id}`, null, rootToken);
+      assert.equal(del.status, 204);
+    }
+    assert.equal(await count(), baseline + 2);
+
+    const list = await apiCall('GET', '/admin/auth/groups?limit=1000', null, rootToken);
+    assert.equal(list.status, 200);
+    assert.equal(list.data.hasMore, false);
+    assert.equal(list.data.items.length, await count());
+  });
+
+  it('@group_by returns per-value bucket counts', async () => {
+    // Values unique to this test, so other groups don't affect the buckets.
+    const tag = Date.now().toString(36);
+    const sources = { [`e2e-a-${tag}`]: 6, [`e2e-b-${tag}`]: 4 };
+    for (const [source, n] of Object.entries(sources)) {
+      for (let i = 0; i < n; i++) {
+        const resp = await apiCall('POST', '/admin/auth/groups', {
+          displayName: `E2E Facet Group ${i}`, externalSource: source,
+        }, rootToken);
+        assert.equal(resp.status, 200);
+        groupIds.push(resp.data.id);
+      }
+    }
+
+    const resp = await apiCall('GET', '/admin/auth/groups/@group_by?field=externalSource&limit=1000', null, rootToken);
+    assert.equal(resp.status, 200);
+    assert.equal(resp.data.field, 'externalSource');
+    const ours = resp.data.buckets.filter(b => b.value in sources);
+    assert.equal(ours.length, 2, `Expected 2 buckets, got ${JSON.stringify(resp.data.buckets)}`);
+    for (const b of ours) {
+      assert.equal(b.count, sources[b.value], `count for ${b.value}`);
+    }
+  });
+
+  it('@count requires authentication', async () => {
+    const anon = await apiCall('GET', '/admin/auth/users/@count');
+    assert.equal(anon.status, 401);
+    assert.equal(anon.data.code, 'UNAUTHENTICATED');
+
+    const authed = await apiCall('GET', '/admin/auth/users/@count', null, rootToken);
+    assert.equal(authed.status, 200);
+    assert.equal(typeof authed.data.count, 'number');
+  });
+
+  it('after cursor pages in stable id order', async () => {
+    for (let i = 0; i < 10; i++) {
+      const resp = await apiCall('POST', '/admin/auth/users', {
+        displayName: `E2E Keyset ${i}`, active: true,
+      }, rootToken);
+      assert.equal(resp.status, 200);
+      userIds.push(resp.data.id);
+    }
+
+    // Lists come back in key order (there is no `sort` parameter), so the
+    // last id of a page is the cursor for the next.
+    const page1 = await apiCall('GET', '/admin/auth/users?limit=5', null, rootToken);
+    assert.equal(page1.status, 200);
+    assert.equal(page1.data.items.length, 5);
+    const ids1 = page1.data.items.map(u => u.id);
+    assert.deepEqual(ids1, [...ids1].sort(), 'page 1 in id order');
+    const cursor = ids1[4];
+
+    const page2 = await apiCall('GET',
+      `/admin/auth/users?limit=5&after=${encodeURIComponent(cursor)}`, null, rootToken);
+    assert.equal(page2.status, 200);
+    const ids2 = page2.data.items.map(u => u.id);
+    assert.ok(ids2.length > 0, 'page 2 not empty');
+    for (const id of ids2) {
+      assert.ok(id > cursor, `${id} sorts after cursor ${cursor}`);
+      assert.ok(!ids1.includes(id), `${id} not repeated from page 1`);
+    }
+  });
+
+  it('listing an empty resource returns no items and @count 0', async () => {
+    // A fresh server: nothing else can have created users there.
+    await withFreshServer('empty', async ({ baseUrl, token }) => {
+      const items = await apiListAll('/admin/auth/users', token, { baseUrl });
+      assert.deepEqual(items, []);
+
+      const count = await apiCall('GET', '/admin/auth/users/@count', null, token, { baseUrl });
+      assert.equal(count.status, 200);
+      assert.equal(count.data.count, 0);
+    });
+  });
+
+  it('@count never goes backwards while records are being created', async () => {
+    await withFreshServer('count-race', async ({ baseUrl, token }) => {
+      const count = async () => {
+        const resp = await apiCall('GET', '/admin/auth/users/@count', null, token, { baseUrl });
+        assert.equal(resp.status, 200);
+        return resp.data.count;
+      };
+      const before = await count();
+
+      const TOTAL = 100;
+      let done = false;
+      const creator = (async () => {
+        try {
+          for (let i = 0; i < TOTAL; i++) {
+            const resp = await apiCall('POST', '/admin/auth/users', {
+              displayName: `E2E Count Race ${i}`, active: true,
+            }, token, { baseUrl });
+            assert.equal(resp.status, 200);
+          }
+        } finally {
+          done = true;
+        }
+      })();
+      const readings = [];
+      const reader = (async () => {
+        while (!done) readings.push(await count());
+      })();
+      await Promise.all([creator, reader]);
+
+      for (let i = 1; i < readings.length; i++) {
+        assert.ok(readings[i] >= readings[i - 1],
+          `count went backwards at reading ${i}: ${readings[i - 1]} → ${readings[i]}`);
+      }
+      assert.ok(readings.every(c => c >= before && c <= before + TOTAL), 'readings in range');
+      assert.equal(await count(), before + TOTAL);
+    });
+  });
+
+  it('@count and list stay within the churn window', async () => {
+    await withFreshServer('count-churn', async ({ baseUrl, token }) => {
+      const CYCLES = 100;
+      let done = false;
+      const churner = (async () => {
+        try {
+          for (let i = 0; i < CYCLES; i++) {
+            const resp = await apiCall('POST', '/admin/auth/users', {
+              displayName: `E2E Churn ${i}`, active: true,
+            }, token, { baseUrl });
+            assert.equal(resp.status, 200);
+            const del = await apiCall('DELETE', `/admin/auth/users/${resp.data.id}`, null, token, { baseUrl });
+            assert.equal(del.status, 204);
+          }
+        } finally {
+          done = true;
+        }
+      })();
+      // Two requests can't be read atomically, so a create may land between
+      // count and list; at most one user exists at any moment, though.
+      const readings = [];
+      const reader = (async () => {
+        while (!done) {
+          const count = await apiCall('GET', '/admin/auth/users/@count', null, token, { baseUrl });
+          const list = await apiCall('GET', '/admin/auth/users?limit=1000', null, token, { baseUrl });
+          assert.equal(count.status, 200);
+          assert.equal(list.status, 200);
+          readings.push({ count: count.data.count, items: list.data.items.length, hasMore: list.data.hasMore });
+        }
+      })();
+      await Promise.all([churner, reader]);
+
+      assert.ok(readings.length > 0, 'reader got at least one reading');
+      for (const [i, r] of readings.entries()) {
+        assert.ok(r.count === 0 || r.count === 1, `reading ${i}: count ${r.count}`);
+        assert.ok(r.items <= 1, `reading ${i}: ${r.items} items listed`);
+        assert.equal(r.hasMore, false, `reading ${i}: hasMore`);
+      }
+      const final = await apiCall('GET', '/admin/auth/users/@count', null, token, { baseUrl });
+      assert.equal(final.data.count, 0);
+      assert.deepEqual(await apiListAll('/admin/auth/users', token, { baseUrl }), []);
+    });
+  });
+
+  it('@group_by per-value counts agree with @count and the list', async () => {
+    // The admin API has no `filter` query; @group_by is its per-value count.
+    await withFreshServer('count-filter', async ({ baseUrl, token }) => {
+      const spec = [[true, 5], [false, 3]];
+      for (const [active, n] of spec) {
+        for (let i = 0; i < n; i++) {
+          const resp = await apiCall('POST', '/admin/auth/users', {
+            displayName: `E2E Filter ${active} ${i}`, active,
+          }, token, { baseUrl });
+          assert.equal(resp.status, 200);
+        }
+      }
+
+      const groups = await apiCall('GET', '/admin/auth/users/@group_by?field=active', null, token, { baseUrl });
+      assert.equal(groups.status, 200);
+      assert.deepEqual(groups.data.buckets, [{ value: true, count: 5 }, { value: false, count: 3 }]);
+
+      const items = await apiListAll('/admin/auth/users', token, { baseUrl });
+      for (const [active, n] of spec) {
+        assert.equal(items.filter(u => u.active === active).length, n, `list: active=${active}`);
+      }
+
+      const count = await apiCall('GET', '/admin/auth/users/@count', null, token, { baseUrl });
+      assert.equal(count.data.count, 8);
+      assert.equal(items.length, 8);
+    });
+  });
+
+  it('@group_by pages through the buckets of a high-cardinality field', async () => {
+    await withFreshServer('group-by-wide', async ({ baseUrl, token }) => {
+      const names = Array.from({ length: 200 }, (_, i) => `E2E Wide ${String(i).padStart(3, '0')}`);
+      for (const displayName of names) {
+        const resp = await apiCall('POST', '/admin/auth/users', {
+          displayName, active: true,
+        }, token, { baseUrl });
+        assert.equal(resp.status, 200);
+      }
+
+      const buckets = [];
+      for (let page = 0; ; page++) {
+        const resp = await apiCall('GET',
+          `/admin/auth/users/@group_by?field=displayName&limit=50&offset=${buckets.length}`,
+          null, token, { baseUrl });
+        assert.equal(resp.status, 200);
+        assert.ok(page < 4, 'hasMore still set after 200 buckets');
+        assert.equal(resp.data.buckets.length, 50, `page ${page} size`);
+        buckets.push(...resp.data.buckets);
+        if (!resp.data.hasMore) break;
+      }
+      assert.equal(buckets.length, 200, 'one bucket per distinct value');
+      const values = buckets.map(b => b.value);
+      assert.equal(new Set(values).size, 200, 'no value repeated across buckets');
+      assert.deepEqual([...values].sort(), names);
+      assert.ok(buckets.every(b => b.count === 1), 'each name used once');
+
+      const count = await apiCall('GET', '/admin/auth/users/@count', null, token, { baseUrl });
+      assert.equal(buckets.reduce((sum, b) => sum + b.count, 0), count.data.count);
+    });
+  });
+
+  it('apiListAll stops at its item limit when hasMore never clears', async () => {
+    // A broken server: one item per page, and always more to come.
+    let requests = 0;
+    const mock = createServer((req, res) => {
+      requests++;
+      res.setHeader('Content-Type', 'application/json');
+      res.end(JSON.stringify({ items: [{ id: '1' }], hasMore: true }));
+    });
+    await new Promise(resolve => mock.listen(0, '127.0.0.1', resolve));
+    try {
+      const baseUrl = `http://127.0.0.1:${mock.address().port}`;
+      await assert.rejects(
+        apiListAll('/admin/auth/users', 'unused', { baseUrl }),
+        /still has more after 10000 items/,
+      );
+      assert.equal(requests, 10_000, 'one request per item up to the limit');
+    } finally {
+      await new Promise(resolve => mock.close(resolve));
+    }
+  });
+});
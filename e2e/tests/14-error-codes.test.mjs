@@ -0,0 +1,95 @@
+/**
+ * E2E Test: Error response bodies
+ *
+ * Every error response must be JSON with a non-empty machine-readable
+ * `code` (see openerp_core::error::error_code), whatever layer produced it:
+ * 1. 404 / 409 / 400 from the admin store; a duplicate key is a plain
+ *    ALREADY_EXISTS, not a raw storage error
+ * 2. 401 from login
+ * 3. 403 from the permission checker (non-root token, signed locally)
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert/strict';
+import {
+  ROOT_USER,
+  ROOT_PASS,
+  waitForServer,
+  apiCall,
+  signToken,
+} from './helpers.mjs';
+
+describe('Error codes', () => {
+  let rootToken;
+  let user;
+
+  before(async () => {
+    await waitForServer();
+    const resp = await apiCall('POST', '/auth/login', {
+      username: ROOT_USER, password: ROOT_PASS,
+    });
+    rootToken = resp.data.access_token;
+
+    const created = await apiCall('POST', '/admin/auth/users', {
+      displayName: 'E2E Error Codes', active: true,
+    }, rootToken);
+    assert.equal(created.status, 200);
+    user = created.data;
+  });
+
+  after(async () => {
+    if (user) await apiCall('DELETE', `/admin/auth/users/${user.id}`, null, rootToken);
+  });
+
+  // Built lazily: rows need the token and record from `before`.
+  const cases = () => [
+    {
+      name: 'missing record',
+      method: 'GET', path: '/admin/auth/users/doesnotexist', token: rootToken,
+      expectedStatus: 404, expectedCode: 'NOT_FOUND',
+    },
+    {
+      name: 'stale updatedAt',
+      method: 'PATCH', path: `/admin/auth/users/${user.id}`, token: rootToken,
+      body: { displayName: 'x', updatedAt: '2000-01-01T00:00:00Z' },
+      expectedStatus: 409, expectedCode: 'ALREADY_EXISTS',
+    },
+    {
+      name: 'duplicate key',
+      method: 'POST', path: '/admin/auth/users', token: rootToken,
+      body: { id: user.id, displayName: 'E2E Error Codes Dup', active: true },
+      expectedStatus: 409, expectedCode: 'ALREADY_EXISTS',
+    },
+    {
+      name: 'URL key does not match body key',
+      method: 'PUT', path: `/admin/auth/users/${user.id}`, token: rootToken,
+      body: { ...user, id: 'someotherid' },
+      expectedStatus: 400, expectedCode: 'VALIDATION_FAILED',
+    },
+    {
+      name: 'wrong password',
+      method: 'POST', path: '/auth/login',
+      body: { username: ROOT_USER, password: 'wrong' },
+      expectedStatus: 401, expectedCode: 'UNAUTHENTICATED',
+    },
+    {
+      name: 'token without roles',
+      method: 'GET', path: '/admin/auth/users', token: signToken({ roles: [] }),
+      expectedStatus: 403, expectedCode: 'PERMISSION_DENIED',
+    },
+  ];
+
+  it('every error body has a non-empty code', async () => {
+    for (const c of cases()) {
+      const resp = await apiCall(c.method, c.path, c.body ?? null, c.token);
+      assert.equal(resp.status, c.expectedStatus, `${c.name}: status`);
+      assert.ok(resp.data && typeof resp.data === 'object', `${c.name}: body is JSON`);
+      assert.equal(typeof resp.data.code, 'string', `${c.name}: code is a string`);
+      assert.ok(resp.data.code.length > 0, `${c.name}: code is non-empty`);
+      assert.equal(resp.data.code, c.expectedCode, `${c.name}: code`);
+      assert.equal(typeof resp.data.message, 'string', `${c.name}: message is a string`);
+      assert.doesNotMatch(resp.data.message, /redb|sqlite|UNIQUE|constraint/i,
+        `${c.name}: storage internals leaked: ${resp.data.message}`);
+    }
+  });
+});
@@ -0,0 +1,327 @@
+/**
+ * E2E Test: Request hardening
+ *
+ * Malformed or abusive requests must be rejected cleanly and leave the
+ * server healthy:
+ * 1. A 32KB Authorization header is rejected (400 / 401 / 431), not a crash
+ * 2. A JSON body nested 500 levels deep is rejected with 400, no stack overflow
+ * 3. Every response, errors included, carries the browser security headers
+ * 4. Malformed or `null` JSON bodies get 400 VALIDATION_FAILED with a JSON
+ *    error body, never a 500 or axum's plain-text rejection
+ * 5. A GET with `Content-Length: 0` or an empty chunked body is answered
+ *    normally, without the server waiting for a body
+ * 6. HTTP/1.1 keep-alive: sequential requests reuse one connection
+ * 7. Percent-encoded ID segments: `%20` and `%2F` reach the handler as part
+ *    of the ID (JSON 404), never a 500, and `%2F` is not a path separator
+ * 8. HTTP/2: if the server speaks it (h2c with prior knowledge; there is no
+ *    TLS listener for ALPN), the API answers as over HTTP/1.1; else skipped
+ * 9. A 5 MB body is a 413 PAYLOAD_TOO_LARGE on PATCH, PUT and POST alike,
+ *    and the server stays healthy
+ * 10. URLs are not canonicalised by redirect: a trailing slash on an admin
+ *    path is answered directly (404, no 3xx / Location), and the canonical
+ *    path is a plain 200
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert/strict';
+import { Agent, request } from 'node:http';
+import { connect } from 'node:http2';
+import {
+  BASE_URL,
+  ROOT_USER,
+  ROOT_PASS,
+  waitForServer,
+  apiCall,
+  apiGetRaw,
+} from './helpers.mjs';
+
+describe('Request hardening', () => {
+  let rootToken;
+
+  before(async () => {
+    await waitForServer();
+    const resp = await apiCall('POST', '/auth/login', {
+      username: ROOT_USER, password: ROOT_PASS,
+    });
+    rootToken = resp.data.access_token;
+  });
+
+  const userIds = [];
+
+  after(async () => {
+    for (const id of userIds) {
+      await apiCall('DELETE', `/admin/auth/users/${id}`, null, rootToken);
+    }
+  });
+
+  it('rejects an oversized Authorization header', async () => {
+    const resp = await apiCall('GET', '/admin/auth/users', null, 'x'.repeat(32 * 1024));
+    // 431 if the HTTP layer caps header size, otherwise the token is
+    // simply invalid; either way it is a client error.
+    assert.ok([400, 401, 431].includes(resp.status), `unexpected status ${resp.status}`);
+
+    const health = await apiCall('GET', '/health');
+    assert.equal(health.status, 200, 'server still healthy');
+  });
+
+  it('rejects a deeply nested JSON body', async () => {
+    const depth = 500;
+    const body = '{"a":'.repeat(depth) + '1' + '}'.repeat(depth);
+    // Raw fetch: the body is built as a string, not via JSON.stringify.
+    const resp = await fetch(`${BASE_URL}/admin/auth/users`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'Authorization': `Bearer ${rootToken}`,
+      },
+      body,
+    });
+    assert.equal(resp.status, 400, `Expected 400, got ${resp.status}`);
+
+    const health = await apiCall('GET', '/health');
+    assert.equal(health.status, 200, 'server still healthy');
+  });
+
+  it('rejects malformed and null JSON bodies with a JSON 400', async () => {
+    const created = await apiCall('POST', '/admin/auth/users', {
+      displayName: 'E2E Bad JSON', active: true,
+    }, rootToken);
+    assert.equal(created.status, 200);
+    const id = created.data.id;
+
+    const cases = [
+      ['POST malformed', 'POST', '/admin/auth/users', '{bad json'],
+      ['POST null', 'POST', '/admin/auth/users', 'null'],
+      ['PATCH malformed', 'PATCH', `/admin/auth/users/${id}`, '{bad json'],
+      ['PATCH null', 'PATCH', `/admin/auth/users/${id}`, 'null'],
+    ];
+    try {
+      for (const [name, method, path, body] of cases) {
+        const resp = await fetch(`${BASE_URL}${path}`, {
+          method,
+          headers: {
+            'Content-Type': 'application/json',
+            'Authorization': `Bearer ${rootToken}`,
+          },
+          body,
+        });
+        assert.equal(resp.status, 400, `${name}: status`);
+        const data = await resp.json();
+        assert.equal(data.code, 'VALIDATION_FAILED', `${name}: code`);
+        assert.ok(data.message, `${name}: message`);
+      }
+
+      const still = await apiCall('GET', `/admin/auth/users/${id}`, null, rootToken);
+      assert.equal(still.data.displayName, 'E2E Bad JSON', 'record untouched');
+    } finally {
+      await apiCall('DELETE', `/admin/auth/users/${id}`, null, rootToken);
+    }
+  });
+
+  it('sets security headers on every response', async () => {
+    const requests = [
+      ['health', '/health', null],
+      ['dashboard page', '/dashboard', null],
+      ['admin list', '/admin/auth/users', rootToken],
+      ['unauthenticated', '/admin/auth/users', null],
+      ['not found', '/admin/auth/users/doesnotexist', rootToken],
+    ];
+    for (const [name, path, token] of requests) {
+      const resp = await apiGetRaw(path, token);
+      await resp.arrayBuffer();
+      assert.equal(resp.headers.get('x-content-type-options'), 'nosniff', `${name}: nosniff`);
+      assert.equal(resp.headers.get('x-frame-options'), 'DENY', `${name}: frame options`);
+      assert.equal(resp.headers.get('referrer-policy'), 'strict-origin-when-cross-origin',
+        `${name}: referrer policy`);
+    }
+  });
+
+  it('answers a GET that declares an empty body', async () => {
+    // fetch() refuses bodies on GET, so go through node:http. A server that
+    // waits for the body would hang until the timeout.
+    const get = (headers) => new Promise((resolve, reject) => {
+      const req = request(`${BASE_URL}/admin/auth/users`, {
+        method: 'GET',
+        headers: { 'Authorization': `Bearer ${rootToken}`, ...headers },
+        timeout: 5000,
+      }, (resp) => {
+        let body = '';
+        resp.setEncoding('utf8');
+        resp.on('data', d => { body += d; });
+        resp.on('end', () => resolve({ status: resp.statusCode, body }));
+      });
+      req.on('timeout', () => req.destroy(new Error('no response within 5s')));
+      req.on('error', reject);
+      req.end();
+    });
+
+    for (const headers of [{ 'Content-Length': '0' }, { 'Transfer-Encoding': 'chunked' }]) {
+      const name = Object.keys(headers)[0];
+      const resp = await get(headers);
+      assert.equal(resp.status, 200, `${name}: status`);
+      assert.ok(Array.isArray(JSON.parse(resp.body).items), `${name}: normal list body`);
+    }
+  });
+
+  it('keeps HTTP/1.1 connections alive across requests', async () => {
+    const agent = new Agent({ keepAlive: true, maxSockets: 1 });
+    const get = () => new Promise((resolve, reject) => {
+      const req = request(`${BASE_URL}/health`, { agent }, (resp) => {
+        resp.resume();
+        resp.on('end', () => resolve({
+          status: resp.statusCode,
+          version: resp.httpVersion,
+          connection: resp.headers.connection,
+          reused: req.reusedSocket,
+        }));
+      });
+      req.on('error', reject);
+      req.end();
+    });
+
+    try {
+      for (let i = 0; i < 5; i++) {
+        const resp = await get();
+        assert.equal(resp.status, 200, `request ${i}: status`);
+        assert.equal(resp.version, '1.1', `request ${i}: HTTP version`);
+        assert.notEqual(resp.connection, 'close', `request ${i}: server closed the connection`);
+        assert.equal(resp.reused, i > 0, `request ${i}: connection reused`);
+      }
+    } finally {
+      agent.destroy();
+    }
+  });
+
+  it('keeps percent-encoded bytes inside the ID segment', async () => {
+    const created = await apiCall('POST', '/admin/auth/users', {
+      displayName: 'E2E Encoded Path', active: true,
+    }, rootToken);
+    assert.equal(created.status, 200);
+    const id = created.data.id;
+    userIds.push(id);
+
+    const got = await apiCall('GET', `/admin/auth/users/${id}`, null, rootToken);
+    assert.equal(got.status, 200, 'plain ID resolves');
+
+    // Decoded to "<id> suffix" / "<id>/suffix": a different, missing record.
+    for (const suffix of ['%20suffix', '%2Fsuffix', '%E6%97%A5%E6%9C%AC']) {
+      const resp = await apiCall('GET', `/admin/auth/users/${id}${suffix}`, null, rootToken);
+      assert.equal(resp.status, 404, `${suffix}: status ${resp.status}`);
+      assert.equal(resp.data?.code, 'NOT_FOUND', `${suffix}: handler answered, not the router`);
+    }
+
+    // An encoded slash must not split "users%2F<id>" into two segments.
+    const joined = await apiCall('GET', `/admin/auth/users%2F${id}`, null, rootToken);
+    assert.equal(joined.status, 404);
+    assert.notEqual(joined.data?.id, id, '%2F was treated as a separator');
+  });
+
+  it('answers API calls over HTTP/2 like HTTP/1.1', async (t) => {
+    const session = connect(BASE_URL);
+    // A real HTTP/2 server opens with its SETTINGS frame; an HTTP/1-only
+    // one rejects the connection preface instead.
+    const h2 = await new Promise((resolve) => {
+      const timer = setTimeout(() => resolve(false), 2000);
+      session.once('remoteSettings', () => { clearTimeout(timer); resolve(true); });
+      session.once('error', () => { clearTimeout(timer); resolve(false); });
+      session.once('close', () => { clearTimeout(timer); resolve(false); });
+    });
+    session.on('error', () => {});
+    if (!h2) {
+      session.destroy();
+      t.skip('openerpd does not speak HTTP/2 (h2c prior knowledge refused)');
+      return;
+    }
+
+    const call = (method, path, body, token) => new Promise((resolve, reject) => {
+      const headers = { ':method': method, ':path': path, 'content-type': 'application/json' };
+      if (token) headers.authorization = `Bearer ${token}`;
+      const req = session.request(headers);
+      let status;
+      const chunks = [];
+      req.on('response', (h) => { status = h[':status']; });
+      req.on('data', (c) => chunks.push(c));
+      req.on('end', () => {
+        const text = Buffer.concat(chunks).toString();
+        resolve({ status, data: text ? JSON.parse(text) : null });
+      });
+      req.on('error', reject);
+      req.end(body ? JSON.stringify(body) : undefined);
+    });
+
+    try {
+      const health = await call('GET', '/health');
+      assert.equal(health.status, 200);
+      assert.equal(health.data.status, (await apiCall('GET', '/health')).data.status);
+
+      const login = await call('POST', '/auth/login', { username: ROOT_USER, password: ROOT_PASS });
+      assert.equal(login.status, 200);
+      assert.ok(login.data.access_token, 'token issued over HTTP/2');
+
+      const count = await call('GET', '/admin/auth/users/@count', null, login.data.access_token);
+      const count1 = await apiCall('GET', '/admin/auth/users/@count', null, rootToken);
+      assert.equal(count.status, 200);
+      assert.equal(count.data.count, count1.data.count, 'same count over both protocols');
+
+      const denied = await call('GET', '/admin/auth/users');
+      const denied1 = await apiCall('GET', '/admin/auth/users');
+      assert.equal(denied.status, denied1.status, 'no token: same rejection');
+    } finally {
+      session.close();
+    }
+  });
+
+  it('rejects an oversized body on every write method with 413', async () => {
+    const created = await apiCall('POST', '/admin/auth/users', {
+      displayName: 'E2E Big Body', active: true,
+    }, rootToken);
+    assert.equal(created.status, 200);
+    const id = created.data.id;
+    userIds.push(id);
+
+    const big = 'x'.repeat(5 * 1024 * 1024);
+    const cases = [
+      ['PATCH', `/admin/auth/users/${id}`, { description: big }],
+      ['PUT', `/admin/auth/users/${id}`, { ...created.data, description: big }],
+      ['POST', '/admin/auth/users', { displayName: 'E2E Big Body 2', description: big }],
+    ];
+    for (const [method, path, body] of cases) {
+      const resp = await apiCall(method, path, body, rootToken);
+      assert.equal(resp.status, 413, `${method}: status`);
+      assert.equal(resp.data?.code, 'PAYLOAD_TOO_LARGE', `${method}: code`);
+    }
+
+    assert.equal((await apiCall('GET', '/health')).status, 200, 'server still healthy');
+    const got = await apiCall('GET', `/admin/auth/users/${id}`, null, rootToken);
+    assert.equal(got.data.updatedAt, created.data.updatedAt, 'record unchanged');
+  });
+
+  it('answers trailing-slash paths without redirecting', async () => {
+    const created = await apiCall('POST', '/admin/auth/users', {
+      displayName: 'E2E Trailing Slash', active: true,
+    }, rootToken);
+    assert.equal(created.status, 200);
+    const id = created.data.id;
+    userIds.push(id);
+
+    // A redirect would turn a POST into a GET on 301/302 and drop the
+    // Authorization header cross-origin, so the API must not issue any.
+    for (const path of ['/admin/auth/users/', `/admin/auth/users/${id}/`]) {
+      const resp = await fetch(`${BASE_URL}${path}`, {
+        headers: { Authorization: `Bearer ${rootToken}` },
+        redirect: 'manual',
+      });
+      assert.ok(resp.status < 300 || resp.status >= 400, `${path}: redirected (${resp.status})`);
+      assert.equal(resp.headers.get('location'), null, `${path}: Location header`);
+      assert.equal(resp.status, 404, `${path}: status`);
+    }
+
+    for (const path of ['/admin/auth/users', `/admin/auth/users/${id}`]) {
+      const resp = await fetch(`${BASE_URL}${path}`, {
+        headers: { Authorization: `Bearer ${rootToken}` },
+        redirect: 'manual',
+      });
+      assert.equal(resp.status, 200, `${path}: status`);
+    }
+  });
+});
@@ -0,0 +1,126 @@
+/**
+ * E2E Test: Field value round-trips
+ *
+ * Values must come back from the server exactly as they were sent:
+ * 1. Unicode across planes (Latin-1, CJK, emoji, RTL Arabic)
+ * 2. Server-stamped createdAt/updatedAt are RFC 3339 in UTC (`Z`), never
+ *    the server's local offset, and close to the client's clock
+ * 3. Server-generated IDs are random UUID v4s, written as 32 lowercase hex
+ *    digits without dashes, and distinct across creates
+ * 4. A string field of exactly 10 000 characters is stored whole; one more
+ *    is a 400 VALIDATION_FAILED naming the field, and nothing is stored
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert/strict';
+import {
+  ROOT_USER,
+  ROOT_PASS,
+  waitForServer,
+  apiCall,
+  apiListAll,
+} from './helpers.mjs';
+
+describe('Field values', () => {
+  let rootToken;
+  const userIds = [];
+
+  before(async () => {
+    await waitForServer();
+    const resp = await apiCall('POST', '/auth/login', {
+      username: ROOT_USER, password: ROOT_PASS,
+    });
+    rootToken = resp.data.access_token;
+  });
+
+  after(async () => {
+    for (const id of userIds) {
+      await apiCall('DELETE', `/admin/auth/users/${id}`, null, rootToken);
+    }
+  });
+
+  it('Unicode displayName round-trips byte-for-byte', async () => {
+    // ASCII, Latin-1, CJK, astral-plane emoji (incl. ZWJ sequence), Arabic.
+    const name = 'E2E Ünïcødé café 日本語 中文 😀👩‍💻 مرحبا';
+    const created = await apiCall('POST', '/admin/auth/users', {
+      displayName: name, active: true,
+    }, rootToken);
+    assert.equal(created.status, 200);
+    userIds.push(created.data.id);
+    assert.equal(created.data.displayName, name);
+
+    const got = await apiCall('GET', `/admin/auth/users/${created.data.id}`, null, rootToken);
+    assert.equal(got.status, 200);
+    assert.deepEqual(
+      Buffer.from(got.data.displayName, 'utf8'),
+      Buffer.from(name, 'utf8'),
+      'UTF-8 bytes identical',
+    );
+  });
+
+  it('createdAt/updatedAt are RFC 3339 UTC near now', async () => {
+    // Fractional seconds are optional; the zone must be a literal Z.
+    const RFC3339_UTC = /^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?Z$/;
+    const t0 = Date.now();
+    const created = await apiCall('POST', '/admin/auth/users', {
+      displayName: 'E2E Timestamp', active: true,
+    }, rootToken);
+    const t1 = Date.now();
+    assert.equal(created.status, 200);
+    userIds.push(created.data.id);
+
+    for (const field of ['createdAt', 'updatedAt']) {
+      const ts = created.data[field];
+      assert.match(ts, RFC3339_UTC, `${field} is UTC with Z: ${ts}`);
+      // Skew guard: the server clock may drift a little from ours.
+      const ms = Date.parse(ts);
+      assert.ok(ms >= t0 - 5000 && ms <= t1 + 5000,
+        `${field} ${ts} within 5s of the POST`);
+    }
+
+    const got = await apiCall('GET', `/admin/auth/users/${created.data.id}`, null, rootToken);
+    assert.equal(got.data.createdAt, created.data.createdAt, 'stored as returned');
+  });
+
+  it('created IDs are dashless UUID v4', async () => {
+    // Version nibble 4, variant 10xx; the store strips the dashes.
+    const UUID_V4 = /^[0-9a-f]{12}4[0-9a-f]{3}[89ab][0-9a-f]{15}$/;
+    const ids = new Set();
+    for (let i = 0; i < 10; i++) {
+      const created = await apiCall('POST', '/admin/auth/users', {
+        displayName: `E2E Id ${i}`, active: true,
+      }, rootToken);
+      assert.equal(created.status, 200);
+      userIds.push(created.data.id);
+      assert.match(created.data.id, UUID_V4, `id #${i}: ${created.data.id}`);
+      ids.add(created.data.id);
+    }
+    assert.equal(ids.size, 10, 'every create gets a new ID');
+  });
+
+  it('string fields are capped at 10 000 characters', async () => {
+    // Counted in characters, not UTF-8 bytes.
+    const atLimit = 'é'.repeat(10_000);
+    const created = await apiCall('POST', '/admin/auth/users', {
+      displayName: atLimit, active: true,
+    }, rootToken);
+    assert.equal(created.status, 200);
+    userIds.push(created.data.id);
+    assert.equal(created.data.displayName, atLimit);
+
+    const overLimit = 'x'.repeat(10_001);
+    const tooLong = await apiCall('POST', '/admin/auth/users', {
+      displayName: overLimit, active: true,
+    }, rootToken);
+    assert.equal(tooLong.status, 400);
+    assert.equal(tooLong.data.code, 'VALIDATION_FAILED');
+    assert.match(tooLong.data.message, /'displayName'/, 'error names the field');
+    const users = await apiListAll('/admin/auth/users', rootToken);
+    assert.ok(!users.some(u => u.displayName === overLimit), 'rejected record not stored');
+
+    const patched = await apiCall('PATCH', `/admin/auth/users/${created.data.id}`, {
+      displayName: `${atLimit}x`,
+    }, rootToken);
+    assert.equal(patched.status, 400, 'the limit applies to PATCH too');
+  });
+});
@@ -0,0 +1,68 @@
+/**
+ * E2E Test: HTTP conditional requests
+ *
+ * Admin GET on a single record sets Last-Modified from `updatedAt`:
+ * 1. If-Modified-Since with that date → 304 with no body;
+ *    after an update → 200 with the new body
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert/strict';
+import {
+  ROOT_USER,
+  ROOT_PASS,
+  waitForServer,
+  apiCall,
+  apiGetRaw,
+} from './helpers.mjs';
+
+describe('HTTP caching', () => {
+  let rootToken;
+  const userIds = [];
+
+  before(async () => {
+    await waitForServer();
+    const resp = await apiCall('POST', '/auth/login', {
+      username: ROOT_USER, password: ROOT_PASS,
+    });
+    rootToken = resp.data.access_token;
+  });
+
+  after(async () => {
+    for (const id of userIds) {
+      await apiCall('DELETE', `/admin/auth/users/${id}`, null, rootToken);
+    }
+  });
+
+  it('If-Modified-Since returns 304 until the record changes', async () => {
+    const created = await apiCall('POST', '/admin/auth/users', {
+      displayName: 'E2E Cache User', active: true,
+    }, rootToken);
+    assert.equal(created.status, 200);
+    const id = created.data.id;
+    userIds.push(id);
+
+    const path = `/admin/auth/users/${id}`;
+
+    const first = await apiGetRaw(path, rootToken);
+    assert.equal(first.status, 200);
+    const lastModified = first.headers.get('last-modified');
+    assert.ok(lastModified, 'Last-Modified header set');
+
+    const cached = await apiGetRaw(path, rootToken, { 'If-Modified-Since': lastModified });
+    assert.equal(cached.status, 304);
+    assert.equal(await cached.text(), '', '304 has no body');
+
+    // HTTP dates have 1s resolution; make sure the update lands in a later second.
+    await new Promise(r => setTimeout(r, 1100));
+    const patch = await apiCall('PATCH', `/admin/auth/users/${id}`, {
+      displayName: 'E2E Cache User Updated',
+    }, rootToken);
+    assert.equal(patch.status, 200);
+
+    const fresh = await apiGetRaw(path, rootToken, { 'If-Modified-Since': lastModified });
+    assert.equal(fresh.status, 200);
+    const body = await fresh.json();
+    assert.equal(body.displayName, 'E2E Cache User Updated');
+  });
+});
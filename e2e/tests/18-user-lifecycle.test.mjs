@@ -0,0 +1,82 @@
+/**
+ * E2E Test: User lifecycle
+ *
+ * One straight-line pass over the admin CRUD path for a single user:
+ * create → read → patch → patch → delete → 404. Each write must refresh
+ * `updatedAt` (the store's revision marker) and the next write uses it.
+ * The delete is checked on the raw response: 204 No Content, empty body.
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert/strict';
+import {
+  ROOT_USER,
+  ROOT_PASS,
+  BASE_URL,
+  waitForServer,
+  apiCall,
+} from './helpers.mjs';
+
+describe('User lifecycle', () => {
+  let rootToken;
+  let userId;
+
+  before(async () => {
+    await waitForServer();
+    const resp = await apiCall('POST', '/auth/login', {
+      username: ROOT_USER, password: ROOT_PASS,
+    });
+    rootToken = resp.data.access_token;
+  });
+
+  after(async () => {
+    // Only reached with a leftover record if an assertion failed mid-way.
+    if (userId) await apiCall('DELETE', `/admin/auth/users/${userId}`, null, rootToken);
+  });
+
+  it('create, read, patch twice, delete', async () => {
+    const path = (id) => `/admin/auth/users/${id}`;
+
+    const created = await apiCall('POST', '/admin/auth/users', {
+      displayName: 'E2E Lifecycle', email: 'lifecycle@test.com', active: true,
+    }, rootToken);
+    assert.equal(created.status, 200);
+    userId = created.data.id;
+    assert.ok(created.data.updatedAt, 'updatedAt set on create');
+
+    const read = await apiCall('GET', path(userId), null, rootToken);
+    assert.equal(read.status, 200);
+    assert.equal(read.data.displayName, 'E2E Lifecycle');
+    assert.equal(read.data.email, 'lifecycle@test.com');
+    assert.equal(read.data.updatedAt, created.data.updatedAt);
+
+    const first = await apiCall('PATCH', path(userId), {
+      displayName: 'E2E Lifecycle 1', updatedAt: read.data.updatedAt,
+    }, rootToken);
+    assert.equal(first.status, 200);
+    assert.equal(first.data.displayName, 'E2E Lifecycle 1');
+    assert.notEqual(first.data.updatedAt, read.data.updatedAt, 'first patch bumps updatedAt');
+
+    const second = await apiCall('PATCH', path(userId), {
+      active: false, updatedAt: first.data.updatedAt,
+    }, rootToken);
+    assert.equal(second.status, 200);
+    assert.equal(second.data.active, false);
+    assert.equal(second.data.displayName, 'E2E Lifecycle 1', 'earlier patch kept');
+    assert.notEqual(second.data.updatedAt, first.data.updatedAt, 'second patch bumps updatedAt');
+
+    const del = await fetch(`${BASE_URL}${path(userId)}`, {
+      method: 'DELETE',
+      headers: { 'Authorization': `Bearer ${rootToken}` },
+    });
+    assert.equal(del.status, 204);
+    assert.equal(await del.text(), '', 'delete has no body');
+    assert.ok(['0', null].includes(del.headers.get('content-length')),
+      `unexpected content-length ${del.headers.get('content-length')}`);
+    assert.equal(del.headers.get('content-type'), null, 'no content-type on 204');
+
+    const gone = await apiCall('GET', path(userId), null, rootToken);
+    assert.equal(gone.status, 404);
+    userId = undefined;
+  });
+});
@@ -2,17 +2,22 @@
  * Shared helpers for E2E tests.
  *
  * Manages browser lifecycle, server health checks, and common operations.
+ * Process helpers (CLI, dedicated servers, token signing) live in
+ * ../shared/openerpd.mjs, which the browser suite uses too.
  */
 
 import puppeteer from 'puppeteer-core';
 import { execSync } from 'node:child_process';
 import { existsSync } from 'node:fs';
+import { signToken as signServerToken } from '../shared/openerpd.mjs';
 
 export const BASE_URL = process.env.BASE_URL || 'http://localhost:8088';
 export const ROOT_USER = 'root';
 export const ROOT_PASS = process.env.ROOT_PASS || 'openerp123';
 export const HEADLESS = process.env.HEADLESS !== 'false';
 export const SLOW_MO = parseInt(process.env.SLOW_MO || '0', 10);
+export const SERVER_CONFIG = process.env.SERVER_CONFIG;
+export { OPENERP_BIN, OPENERPD_BIN } from '../shared/openerpd.mjs';
 
 /**
  * Find a usable Chrome/Chromium executable.
@@ -121,18 +126,21 @@ export async function getToken(page) {
 }
 
 /**
- * Make an API call directly (bypassing UI).
+ * Make an API call directly (bypassing UI). `baseUrl` targets a dedicated
+ * server from `startServer` instead of the shared one; `headers` are sent
+ * as well (e.g. from `traceHeaders`).
  */
-export async function apiCall(method, path, body, token) {
+export async function apiCall(method, path, body, token, { baseUrl = BASE_URL, headers = {} } = {}) {
   const opts = {
     method,
     headers: {
       'Content-Type': 'application/json',
+      ...headers,
     },
   };
   if (token) opts.headers['Authorization'] = `Bearer ${token}`;
   if (body) opts.body = JSON.stringify(body);
-  const resp = await fetch(`${BASE_URL}${path}`, opts);
+  const resp = await fetch(`${baseUrl}${path}`, opts);
   if (resp.status === 204) return { status: 204, data: null };
   const text = await resp.text();
   let data = null;
@@ -140,6 +148,63 @@ export async function apiCall(method, path, body, token) {
   return { status: resp.status, data };
 }
 
+/**
+ * GET a path and return the raw fetch Response, for status and header
+ * checks. The body is left unread; extra request headers go in `headers`.
+ */
+export async function apiGetRaw(path, token, headers = {}) {
+  const opts = { headers: { ...headers } };
+  if (token) opts.headers['Authorization'] = `Bearer ${token}`;
+  return fetch(`${BASE_URL}${path}`, opts);
+}
+
+/**
+ * Fetch every item of an admin list by following `hasMore` with offset
+ * pages. Stops on an empty page, and throws once `maxItems` are collected
+ * while the server still claims `hasMore`, so a server that never stops
+ * paging cannot loop forever.
+ */
+export async function apiListAll(path, token, {
+  baseUrl = BASE_URL, headers = {}, pageSize = 100, maxItems = 10_000,
+} = {}) {
+  const items = [];
+  const sep = path.includes('?') ? '&' : '?';
+  for (;;) {
+    const resp = await apiCall('GET', `${path}${sep}limit=${pageSize}&offset=${items.length}`,
+      null, token, { baseUrl, headers });
+    if (resp.status !== 200) {
+      throw new Error(`list ${path} failed: ${resp.status} ${JSON.stringify(resp.data)}`);
+    }
+    const page = resp.data.items ?? [];
+    items.push(...page);
+    if (!resp.data.hasMore || page.length === 0) return items;
+    if (items.length >= maxItems) {
+      throw new Error(`list ${path} still has more after ${items.length} items (maxItems ${maxItems})`);
+    }
+  }
+}
+
+/**
+ * Send a CORS preflight (`OPTIONS` with `Origin` and
+ * `Access-Control-Request-Method`, plus `Access-Control-Request-Headers`
+ * when `requestHeaders` is given) and return the response headers.
+ */
+export async function apiOptions(path, origin, requestMethod, { baseUrl = BASE_URL, requestHeaders } = {}) {
+  const headers = { 'Origin': origin, 'Access-Control-Request-Method': requestMethod };
+  if (requestHeaders) headers['Access-Control-Request-Headers'] = requestHeaders;
+  const resp = await fetch(`${baseUrl}${path}`, { method: 'OPTIONS', headers });
+  await resp.arrayBuffer();
+  return resp.headers;
+}
+
+/**
+ * W3C Trace Context headers for one sampled span: `traceId` is 32 hex
+ * digits, `spanId` 16. Pass as `headers` to the API helpers.
+ */
+export function traceHeaders(traceId, spanId) {
+  return { traceparent: `00-${traceId}-${spanId}-01` };
+}
+
 /**
  * Clean up test data: delete all users, roles, models created during tests.
  */
@@ -167,3 +232,14 @@ export async function cleanupTestData(token) {
     // Best effort cleanup.
   }
 }
+
+export { runCli, createContext, freePort, startServer } from '../shared/openerpd.mjs';
+
+/**
+ * Sign an HS256 JWT with the shared server's secret (read from SERVER_CONFIG).
+ * Lets tests act as a non-root user without a password login.
+ */
+export function signToken(claims, configPath = SERVER_CONFIG) {
+  if (!configPath) throw new Error('SERVER_CONFIG is not set');
+  return signServerToken(claims, configPath);
+}
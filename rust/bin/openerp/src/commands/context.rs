@@ -6,6 +6,45 @@ use anyhow::Result;
 
 use crate::config::{ClientConfig, Context};
 
+/// Render the server config TOML written by `context create`.
+///
+/// Values go through `toml::Value` so paths with backslashes or quotes
+/// (e.g. Windows data dirs) stay valid TOML.
+fn render_server_config(password_hash: &str, data_dir: &str, jwt_secret: &str) -> String {
+    let quote = |s: &str| toml::Value::String(s.to_string()).to_string();
+    format!(
+        r#"[root]
+password_hash = {}
+
+[storage]
+data_dir = {}
+
+[jwt]
+secret = {}
+expire_secs = 86400
+"#,
+        quote(password_hash),
+        quote(data_dir),
+        quote(jwt_secret),
+    )
+}
+
+/// Check a context name. It becomes the file name `<config-dir>/<name>.toml`
+/// (and the default data dir), so only letters, digits, `.`, `_` and `-`
+/// are allowed, and no leading dot: no path separators, `..` or spaces.
+pub fn validate_name(name: &str) -> Result<()> {
+    let valid = !name.is_empty()
+        && !name.starts_with('.')
+        && name.chars().all(|c| c.is_ascii_alphanumeric() || matches!(c, '.' | '_' | '-'));
+    if !valid {
+        anyhow::bail!(
+            "Invalid context name \"{}\": use letters, digits, '.', '_' and '-', not starting with '.'.",
+            name
+        );
+    }
+    Ok(())
+}
+
 /// Create a new context — generate server config + register in client config.
 pub fn create(
     name: &str,
@@ -14,6 +53,8 @@ pub fn create(
     password: &str,
     client_config_path: &std::path::Path,
 ) -> Result<()> {
+    validate_name(name)?;
+
     // Hash the root password with argon2id.
     use argon2::Argon2;
     use password_hash::rand_core::OsRng;
@@ -33,19 +74,7 @@ pub fn create(
         (0..32).map(|_| format!("{:02x}", rng.r#gen::<u8>())).collect()
     };
 
-    // Build server config TOML.
-    let server_config = format!(
-        r#"[root]
-password_hash = "{password_hash}"
-
-[storage]
-data_dir = "{data_dir}"
-
-[jwt]
-secret = "{jwt_secret}"
-expire_secs = 86400
-"#
-    );
+    let server_config = render_server_config(&password_hash, data_dir, &jwt_secret);
 
     // Write server config file.
     let config_path = PathBuf::from(config_dir).join(format!("{}.toml", name));
@@ -56,17 +85,18 @@ expire_secs = 86400
     std::fs::create_dir_all(data_dir)?;
 
     // Update client config.
-    let mut client_config = ClientConfig::load(client_config_path)?;
-    client_config.upsert_context(Context {
-        name: name.to_string(),
-        config_path: config_path.to_string_lossy().to_string(),
-        server: String::new(),
-        token: String::new(),
-    });
-    if client_config.current_context.is_empty() {
-        client_config.current_context = name.to_string();
-    }
-    client_config.save(client_config_path)?;
+    ClientConfig::update(client_config_path, |client_config| {
+        client_config.upsert_context(Context {
+            name: name.to_string(),
+            config_path: config_path.to_string_lossy().to_string(),
+            server: String::new(),
+            token: String::new(),
+        });
+        if client_config.current_context.is_empty() {
+            client_config.current_context = name.to_string();
+        }
+        Ok(())
+    })?;
 
     println!("Context \"{}\" created.", name);
     println!("  Config: {}", config_path.display());
@@ -106,14 +136,13 @@ pub fn list(client_config_path: &std::path::Path) -> Result<()> {
 
 /// Switch current context.
 pub fn use_context(name: &str, client_config_path: &std::path::Path) -> Result<()> {
-    let mut config = ClientConfig::load(client_config_path)?;
-
-    if !config.contexts.iter().any(|c| c.name == name) {
-        anyhow::bail!("Context \"{}\" not found. Run `openerp context list` to see available contexts.", name);
-    }
-
-    config.current_context = name.to_string();
-    config.save(client_config_path)?;
+    ClientConfig::update(client_config_path, |config| {
+        if !config.contexts.iter().any(|c| c.name == name) {
+            anyhow::bail!("Context \"{}\" not found. Run `openerp context list` to see available contexts.", name);
+        }
+        config.current_context = name.to_string();
+        Ok(())
+    })?;
     println!("Switched to context \"{}\".", name);
     Ok(())
 }
@@ -124,30 +153,109 @@ pub fn set(
     server: Option<&str>,
     client_config_path: &std::path::Path,
 ) -> Result<()> {
-    let mut config = ClientConfig::load(client_config_path)?;
-
-    let ctx = config
-        .get_mut(name)
-        .ok_or_else(|| anyhow::anyhow!("Context \"{}\" not found.", name))?;
-
-    if let Some(s) = server {
-        ctx.server = s.to_string();
-    }
+    ClientConfig::update(client_config_path, |config| {
+        let ctx = config
+            .get_mut(name)
+            .ok_or_else(|| anyhow::anyhow!("Context \"{}\" not found.", name))?;
 
-    config.save(client_config_path)?;
+        if let Some(s) = server {
+            ctx.server = s.to_string();
+        }
+        Ok(())
+    })?;
     println!("Context \"{}\" updated.", name);
     Ok(())
 }
 
 /// Delete a context (doesn't delete server config file).
 pub fn delete(name: &str, client_config_path: &std::path::Path) -> Result<()> {
-    let mut config = ClientConfig::load(client_config_path)?;
+    ClientConfig::update(client_config_path, |config| {
+        if !config.remove_context(name) {
+            anyhow::bail!("Context \"{}\" not found.", name);
+        }
+        Ok(())
+    })?;
+    println!("Context \"{}\" deleted.", name);
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
 
-    if !config.remove_context(name) {
-        anyhow::bail!("Context \"{}\" not found.", name);
+    #[test]
+    fn test_validate_name() {
+        for ok in ["cn-stage", "us_prod", "test.dot", "e2e-test", "v1.2"] {
+            assert!(validate_name(ok).is_ok(), "{ok}");
+        }
+        for bad in ["", "test/slash", "test space", "..", ".hidden", "a\\b", "../etc", "日本"] {
+            assert!(validate_name(bad).is_err(), "{bad:?}");
+        }
     }
 
-    config.save(client_config_path)?;
-    println!("Context \"{}\" deleted.", name);
-    Ok(())
+    #[test]
+    fn test_server_config_round_trips() {
+        let rendered = render_server_config(
+            "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$aGFzaA",
+            r#"C:\Users\o'neil\"data""#,
+            "deadbeef",
+        );
+
+        let parsed: toml::Value = toml::from_str(&rendered).unwrap();
+        let reserialized = toml::to_string(&parsed).unwrap();
+        let reparsed: toml::Value = toml::from_str(&reserialized).unwrap();
+        assert_eq!(parsed, reparsed);
+
+        assert_eq!(
+            parsed["storage"]["data_dir"].as_str(),
+            Some(r#"C:\Users\o'neil\"data""#)
+        );
+        assert_eq!(parsed["jwt"]["expire_secs"].as_integer(), Some(86400));
+    }
+
+    #[test]
+    fn test_create_writes_valid_client_config() {
+        let dir = tempfile::tempdir().unwrap();
+        let client = dir.path().join("client.toml");
+        let config_dir = dir.path().join("config");
+        let data_dir = dir.path().join("data");
+        for name in ["first", "second"] {
+            create(
+                name,
+                config_dir.to_str().unwrap(),
+                data_dir.join(name).to_str().unwrap(),
+                "secret",
+                &client,
+            )
+            .unwrap();
+        }
+
+        // Parse generically, not through ClientConfig, to check the file itself.
+        let text = std::fs::read_to_string(&client).unwrap();
+        let parsed: toml::Value = toml::from_str(&text).unwrap();
+        // The first context created stays active.
+        assert_eq!(parsed["current-context"].as_str(), Some("first"));
+        let contexts = parsed["contexts"].as_array().expect("contexts array");
+        let names: Vec<_> = contexts
+            .iter()
+            .map(|c| c["name"].as_str().unwrap())
+            .collect();
+        assert_eq!(names, ["first", "second"]);
+
+        // Each context points at its server config, which holds its data dir.
+        for ctx in contexts {
+            let name = ctx["name"].as_str().unwrap();
+            let config_path = ctx["config_path"].as_str().expect("config_path");
+            assert_eq!(
+                std::path::Path::new(config_path),
+                config_dir.join(format!("{name}.toml"))
+            );
+            let server: toml::Value =
+                toml::from_str(&std::fs::read_to_string(config_path).unwrap()).unwrap();
+            assert_eq!(
+                server["storage"]["data_dir"].as_str(),
+                data_dir.join(name).to_str()
+            );
+        }
+    }
 }
@@ -10,9 +10,9 @@ pub fn login(
     password: &str,
     client_config_path: &std::path::Path,
 ) -> Result<()> {
-    let mut config = ClientConfig::load(client_config_path)?;
-
-    let ctx = config
+    // Read-only here: the config lock is only taken to store the token,
+    // not held across the request.
+    let ctx = ClientConfig::load(client_config_path)?
         .current()
         .ok_or_else(|| anyhow::anyhow!("No current context. Run `openerp use context <name>`."))?
         .clone();
@@ -51,11 +51,13 @@ pub fn login(
         .ok_or_else(|| anyhow::anyhow!("No access_token in response"))?;
 
     // Save token to context.
-    let ctx_mut = config
-        .get_mut(&ctx.name)
-        .ok_or_else(|| anyhow::anyhow!("Context disappeared"))?;
-    ctx_mut.token = token.to_string();
-    config.save(client_config_path)?;
+    ClientConfig::update(client_config_path, |config| {
+        let ctx_mut = config
+            .get_mut(&ctx.name)
+            .ok_or_else(|| anyhow::anyhow!("Context disappeared"))?;
+        ctx_mut.token = token.to_string();
+        Ok(())
+    })?;
 
     println!("Logged in as {}.", username);
     println!("Token saved to context \"{}\".", ctx.name);
@@ -64,19 +66,19 @@ pub fn login(
 
 /// Logout — clear token from current context.
 pub fn logout(client_config_path: &std::path::Path) -> Result<()> {
-    let mut config = ClientConfig::load(client_config_path)?;
-
-    let current_name = config.current_context.clone();
-    if current_name.is_empty() {
-        anyhow::bail!("No current context.");
-    }
+    let current_name = ClientConfig::update(client_config_path, |config| {
+        let current_name = config.current_context.clone();
+        if current_name.is_empty() {
+            anyhow::bail!("No current context.");
+        }
 
-    let ctx = config
-        .get_mut(&current_name)
-        .ok_or_else(|| anyhow::anyhow!("Current context not found."))?;
+        let ctx = config
+            .get_mut(&current_name)
+            .ok_or_else(|| anyhow::anyhow!("Current context not found."))?;
 
-    ctx.token = String::new();
-    config.save(client_config_path)?;
+        ctx.token = String::new();
+        Ok(current_name)
+    })?;
     println!("Logged out from context \"{}\".", current_name);
     Ok(())
 }
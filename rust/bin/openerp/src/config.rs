@@ -63,15 +63,44 @@ impl ClientConfig {
     }
 
     /// Save config to disk.
+    ///
+    /// Writes to a temp file and renames it into place, so readers never
+    /// observe a partially written config.
     pub fn save(&self, path: &Path) -> anyhow::Result<()> {
         if let Some(parent) = path.parent() {
             std::fs::create_dir_all(parent)?;
         }
         let content = toml::to_string_pretty(self)?;
-        std::fs::write(path, content)?;
+        let tmp = sibling_path(path, ".tmp");
+        std::fs::write(&tmp, content)?;
+        std::fs::rename(&tmp, path)?;
         Ok(())
     }
 
+    /// Load, modify and save the config while holding an exclusive lock.
+    ///
+    /// Concurrent CLI invocations (e.g. parallel `context create`) are
+    /// serialized on `<path>.lock`, so no update is lost.
+    pub fn update<R>(
+        path: &Path,
+        f: impl FnOnce(&mut Self) -> anyhow::Result<R>,
+    ) -> anyhow::Result<R> {
+        if let Some(parent) = path.parent() {
+            std::fs::create_dir_all(parent)?;
+        }
+        let lock = std::fs::OpenOptions::new()
+            .create(true)
+            .truncate(false)
+            .write(true)
+            .open(sibling_path(path, ".lock"))?;
+        lock.lock()?;
+
+        let mut config = Self::load(path)?;
+        let result = f(&mut config)?;
+        config.save(path)?;
+        Ok(result)
+    }
+
     /// Get the currently active context, if any.
     pub fn current(&self) -> Option<&Context> {
         self.contexts.iter().find(|c| c.name == self.current_context)
@@ -102,6 +131,13 @@ impl ClientConfig {
     }
 }
 
+/// `path` with `suffix` appended to its file name (e.g. `config.toml.lock`).
+fn sibling_path(path: &Path, suffix: &str) -> PathBuf {
+    let mut name = path.file_name().unwrap_or_default().to_os_string();
+    name.push(suffix);
+    path.with_file_name(name)
+}
+
 /// Return the OpenERP config directory (~/.openerp).
 fn dirs_path() -> PathBuf {
     let home = std::env::var("HOME")
@@ -138,4 +174,28 @@ mod tests {
         assert_eq!(back.contexts.len(), 1);
         assert_eq!(back.contexts[0].server, "http://localhost:8080");
     }
+
+    #[test]
+    fn test_update_persists_changes() {
+        let dir = tempfile::tempdir().unwrap();
+        let path = dir.path().join("client.toml");
+
+        for name in ["a", "b"] {
+            ClientConfig::update(&path, |c| {
+                c.upsert_context(Context {
+                    name: name.to_string(),
+                    config_path: String::new(),
+                    server: String::new(),
+                    token: String::new(),
+                });
+                Ok(())
+            })
+            .unwrap();
+        }
+
+        let config = ClientConfig::load(&path).unwrap();
+        let names: Vec<&str> = config.contexts.iter().map(|c| c.name.as_str()).collect();
+        assert_eq!(names, ["a", "b"]);
+        assert!(!dir.path().join("client.toml.tmp").exists(), "temp file renamed away");
+    }
 }
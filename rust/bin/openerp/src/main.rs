@@ -10,7 +10,7 @@ use clap::{Parser, Subcommand};
 
 /// OpenERP CLI tool.
 #[derive(Parser, Debug)]
-#[command(name = "openerp", about = "OpenERP CLI client")]
+#[command(name = "openerp", about = "OpenERP CLI client", version)]
 struct Cli {
     /// Path to client config file (default: ~/.openerp/config.toml).
     #[arg(long = "config", global = true)]
@@ -175,6 +175,8 @@ fn main() -> anyhow::Result<()> {
                 data_dir,
                 password,
             } => {
+                // Before prompting: the name picks the file paths below.
+                commands::context::validate_name(&name)?;
                 let data_dir = data_dir.unwrap_or_else(|| {
                     format!("/var/lib/openerp/{}", name)
                 });
@@ -313,7 +315,7 @@ fn main() -> anyhow::Result<()> {
         }
 
         Commands::Version => {
-            println!("openerp cli v{}", env!("CARGO_PKG_VERSION"));
+            println!("openerp cli {}", env!("CARGO_PKG_VERSION"));
         }
     }
 
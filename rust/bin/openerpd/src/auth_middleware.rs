@@ -10,6 +10,7 @@ use axum::http::StatusCode;
 use axum::middleware::Next;
 use axum::response::{IntoResponse, Response};
 use jsonwebtoken::{DecodingKey, Validation};
+use openerp_core::error::error_code;
 use serde::{Deserialize, Serialize};
 
 use crate::bootstrap::ROOT_ROLE_ID;
@@ -62,12 +63,13 @@ pub enum AuthError {
 
 impl IntoResponse for AuthError {
     fn into_response(self) -> Response {
-        let (status, msg) = match self {
-            AuthError::MissingToken => (StatusCode::UNAUTHORIZED, "missing authorization token".to_string()),
-            AuthError::InvalidToken(e) => (StatusCode::UNAUTHORIZED, format!("invalid token: {}", e)),
-            AuthError::PermissionDenied(e) => (StatusCode::FORBIDDEN, format!("permission denied: {}", e)),
+        let (status, code, msg) = match self {
+            AuthError::MissingToken => (StatusCode::UNAUTHORIZED, error_code::UNAUTHENTICATED, "missing authorization token".to_string()),
+            AuthError::InvalidToken(e) => (StatusCode::UNAUTHORIZED, error_code::UNAUTHENTICATED, format!("invalid token: {}", e)),
+            AuthError::PermissionDenied(e) => (StatusCode::FORBIDDEN, error_code::PERMISSION_DENIED, format!("permission denied: {}", e)),
         };
-        let body = serde_json::json!({ "error": msg });
+        // `error` is kept alongside `message` for older clients.
+        let body = serde_json::json!({ "code": code, "message": msg, "error": msg });
         (status, axum::Json(body)).into_response()
     }
 }
@@ -87,7 +87,9 @@ mod tests {
             jwt: crate::config::JwtConfig {
                 secret: "test".to_string(),
                 expire_secs: 3600,
+                leeway_secs: 60,
             },
+            http: Default::default(),
         };
         assert!(verify_config(&config).is_err());
     }
@@ -30,18 +30,38 @@ pub struct JwtConfig {
     /// Token expiration in seconds (default: 86400 = 24h).
     #[serde(default = "default_expire_secs")]
     pub expire_secs: u64,
+
+    /// Seconds a token is still accepted past its expiry, to absorb clock
+    /// skew (default: 60).
+    #[serde(default = "default_leeway_secs")]
+    pub leeway_secs: u64,
 }
 
 fn default_expire_secs() -> u64 {
     86400
 }
 
+fn default_leeway_secs() -> u64 {
+    60
+}
+
+/// HTTP section — optional; browser-facing policy.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct HttpConfig {
+    /// Origins allowed to make cross-origin requests (CORS). Empty means
+    /// no CORS headers are sent, so browsers allow same-origin only.
+    #[serde(default)]
+    pub allowed_origins: Vec<String>,
+}
+
 /// Top-level server configuration.
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct ServerConfig {
     pub root: RootConfig,
     pub storage: StorageConfig,
     pub jwt: JwtConfig,
+    #[serde(default)]
+    pub http: HttpConfig,
 }
 
 impl ServerConfig {
@@ -95,10 +115,50 @@ data_dir = "/var/lib/openerp/test"
 [jwt]
 secret = "test-secret-256-bits"
 expire_secs = 3600
+leeway_secs = 0
 "#;
         let config: ServerConfig = toml::from_str(toml_str).unwrap();
         assert_eq!(config.storage.data_dir, "/var/lib/openerp/test");
         assert_eq!(config.jwt.expire_secs, 3600);
+        assert_eq!(config.jwt.leeway_secs, 0);
         assert!(config.root.password_hash.starts_with("$argon2id"));
+        assert!(config.http.allowed_origins.is_empty());
+    }
+
+    #[test]
+    fn test_parse_minimal_config() {
+        let toml_str = r#"
+[root]
+password_hash = "x"
+
+[storage]
+data_dir = "/tmp"
+
+[jwt]
+secret = "s"
+"#;
+        let config: ServerConfig = toml::from_str(toml_str).unwrap();
+        assert_eq!(config.jwt.expire_secs, 86400);
+        assert_eq!(config.jwt.leeway_secs, 60);
+        assert!(config.http.allowed_origins.is_empty());
+    }
+
+    #[test]
+    fn test_parse_allowed_origins() {
+        let toml_str = r#"
+[root]
+password_hash = "x"
+
+[storage]
+data_dir = "/tmp"
+
+[jwt]
+secret = "s"
+
+[http]
+allowed_origins = ["http://trusted.example.com"]
+"#;
+        let config: ServerConfig = toml::from_str(toml_str).unwrap();
+        assert_eq!(config.http.allowed_origins, ["http://trusted.example.com"]);
     }
 }
@@ -8,6 +8,8 @@ use axum::http::StatusCode;
 use jsonwebtoken::{encode, EncodingKey, Header};
 use serde::{Deserialize, Serialize};
 
+use openerp_core::error::error_code;
+
 use crate::auth_middleware::Claims;
 use crate::bootstrap::{verify_root_password, ROOT_ROLE_ID};
 use crate::routes::AppState;
@@ -49,7 +51,7 @@ fn handle_root_login(state: &AppState, password: &str) -> axum::response::Respon
     if !verify_root_password(password, &config.root.password_hash) {
         return (
             StatusCode::UNAUTHORIZED,
-            axum::Json(serde_json::json!({"error": "invalid credentials"})),
+            error_body(error_code::UNAUTHENTICATED, "invalid credentials"),
         ).into_response();
     }
 
@@ -76,14 +78,14 @@ fn handle_user_login(state: &AppState, email: &str, password: &str) -> axum::res
         Ok(None) => {
             return (
                 StatusCode::UNAUTHORIZED,
-                axum::Json(serde_json::json!({"error": "invalid credentials"})),
+                error_body(error_code::UNAUTHENTICATED, "invalid credentials"),
             ).into_response();
         }
         Err(e) => {
             tracing::error!("Failed to find user: {}", e);
             return (
                 StatusCode::INTERNAL_SERVER_ERROR,
-                axum::Json(serde_json::json!({"error": "internal server error"})),
+                error_body(error_code::INTERNAL, "internal server error"),
             ).into_response();
         }
     };
@@ -94,7 +96,7 @@ fn handle_user_login(state: &AppState, email: &str, password: &str) -> axum::res
         _ => {
             return (
                 StatusCode::UNAUTHORIZED,
-                axum::Json(serde_json::json!({"error": "account has no password set"})),
+                error_body(error_code::UNAUTHENTICATED, "account has no password set"),
             ).into_response();
         }
     };
@@ -102,14 +104,14 @@ fn handle_user_login(state: &AppState, email: &str, password: &str) -> axum::res
     if !auth::store_impls::verify_password(password, hash) {
         return (
             StatusCode::UNAUTHORIZED,
-            axum::Json(serde_json::json!({"error": "invalid credentials"})),
+            error_body(error_code::UNAUTHENTICATED, "invalid credentials"),
         ).into_response();
     }
 
     if !user.active {
         return (
             StatusCode::FORBIDDEN,
-            axum::Json(serde_json::json!({"error": "account is deactivated"})),
+            error_body(error_code::PERMISSION_DENIED, "account is deactivated"),
         ).into_response();
     }
 
@@ -151,8 +153,14 @@ fn issue_jwt(state: &AppState, claims: &Claims, expire_secs: u64) -> axum::respo
             tracing::error!("Failed to encode JWT: {}", e);
             (
                 StatusCode::INTERNAL_SERVER_ERROR,
-                axum::Json(serde_json::json!({"error": "internal server error"})),
+                error_body(error_code::INTERNAL, "internal server error"),
             ).into_response()
         }
     }
 }
+
+/// Login error body: the standard `{"code", "message"}` shape plus the
+/// legacy `error` field older clients read.
+fn error_body(code: &str, msg: &str) -> axum::Json<serde_json::Value> {
+    axum::Json(serde_json::json!({"code": code, "message": msg, "error": msg}))
+}
@@ -21,7 +21,7 @@ use routes::AppState;
 
 /// OpenERP server.
 #[derive(Parser, Debug)]
-#[command(name = "openerpd", about = "OpenERP server")]
+#[command(name = "openerpd", about = "OpenERP server", version)]
 struct Cli {
     /// Context name or path to config file.
     #[arg(short = 'c', long = "config", required = true)]
@@ -30,11 +30,18 @@ struct Cli {
     /// Listen address (overrides default 0.0.0.0:8080).
     #[arg(long = "listen", default_value = "0.0.0.0:8080")]
     listen: String,
+
+    /// Serve reads only: every write request gets 403 READ_ONLY.
+    #[arg(long = "read-only")]
+    read_only: bool,
 }
 
 #[tokio::main]
 async fn main() -> anyhow::Result<()> {
+    // Logs (the access log included) go to stderr, like other diagnostics;
+    // stdout stays free for anything a caller may want to pipe.
     tracing_subscriber::fmt()
+        .with_writer(std::io::stderr)
         .with_env_filter(
             tracing_subscriber::EnvFilter::try_from_default_env()
                 .unwrap_or_else(|_| "info".into()),
@@ -74,7 +81,7 @@ async fn main() -> anyhow::Result<()> {
             Arc::clone(&kv),
             &server_config.jwt.secret,
             bootstrap::ROOT_ROLE_ID,
-        ));
+        ).with_leeway(server_config.jwt.leeway_secs));
 
     let admin_routes: Vec<(&str, axum::Router)> = vec![
         ("auth", auth::admin_router(Arc::clone(&kv), authenticator.clone())),
@@ -119,9 +126,11 @@ async fn main() -> anyhow::Result<()> {
     schema_json["facets"] = serde_json::Value::Array(facet_info);
 
     // Build JWT state for middleware.
+    let mut validation = Validation::default();
+    validation.leeway = server_config.jwt.leeway_secs;
     let jwt_state = Arc::new(JwtState {
         decoding_key: DecodingKey::from_secret(server_config.jwt.secret.as_bytes()),
-        validation: Validation::default(),
+        validation,
     });
 
     let server_config = Arc::new(server_config);
@@ -130,13 +139,21 @@ async fn main() -> anyhow::Result<()> {
         jwt_state,
         server_config,
         kv,
+        read_only: cli.read_only,
     };
+    if cli.read_only {
+        info!("Read-only mode: write requests are rejected");
+    }
 
     let app = routes::build_router(app_state, admin_routes, facet_routes, schema_json);
 
     let listener = tokio::net::TcpListener::bind(&cli.listen).await?;
     info!("OpenERP server listening on {}", cli.listen);
-    axum::serve(listener, app).await?;
+    axum::serve(
+        listener,
+        app.into_make_service_with_connect_info::<std::net::SocketAddr>(),
+    )
+    .await?;
 
     Ok(())
 }
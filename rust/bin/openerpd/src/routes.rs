@@ -1,11 +1,16 @@
 //! Route registration — admin routes + system endpoints.
 
+use std::net::SocketAddr;
 use std::sync::Arc;
 
-use axum::response::{Html, IntoResponse};
+use axum::extract::{ConnectInfo, Request, State};
+use axum::http::{header, HeaderValue, Method, StatusCode};
+use axum::middleware::Next;
+use axum::response::{Html, IntoResponse, Response};
 use axum::routing::get;
 use axum::Router;
 use axum::middleware;
+use tower_http::cors::{AllowOrigin, CorsLayer};
 
 use crate::auth_middleware::{self, JwtState};
 use crate::login;
@@ -16,6 +21,8 @@ pub struct AppState {
     pub jwt_state: Arc<JwtState>,
     pub server_config: Arc<crate::config::ServerConfig>,
     pub kv: Arc<dyn openerp_kv::KVStore>,
+    /// `--read-only`: reject every write request.
+    pub read_only: bool,
 }
 
 /// Build the complete router.
@@ -26,11 +33,14 @@ pub fn build_router(
     schema_json: serde_json::Value,
 ) -> Router {
     let jwt_state = state.jwt_state.clone();
+    let read_only = state.read_only;
+    let allowed_origins = state.server_config.http.allowed_origins.clone();
 
     // System endpoints (public).
     let system_routes = Router::new()
         .route("/health", get(health))
-        .route("/version", get(version));
+        .route("/version", get(version))
+        .with_state(state.clone());
 
     // Schema endpoint.
     let schema = schema_json.clone();
@@ -63,10 +73,127 @@ pub fn build_router(
         app = app.nest(&prefix, facet.router);
     }
 
-    app.layer(middleware::from_fn_with_state(
+    // Inside auth, so an unauthenticated write is still a 401.
+    if read_only {
+        app = app.layer(middleware::from_fn(reject_writes));
+    }
+
+    // CORS sits outside auth so preflight requests (no token) get answered.
+    app = app.layer(middleware::from_fn_with_state(
         jwt_state,
         auth_middleware::auth_middleware,
-    ))
+    ));
+    if let Some(cors) = cors_layer(&allowed_origins) {
+        app = app.layer(cors);
+    }
+
+    // Access log is the outer layer so rejected requests are logged too.
+    app.layer(middleware::from_fn(security_headers))
+    .layer(middleware::from_fn(access_log))
+}
+
+/// CORS for `[http] allowed_origins`; `None` (no CORS headers) when empty.
+/// Origins that are not valid header values are skipped with a warning.
+fn cors_layer(origins: &[String]) -> Option<CorsLayer> {
+    let origins: Vec<HeaderValue> = origins
+        .iter()
+        .filter_map(|o| match HeaderValue::from_str(o) {
+            Ok(v) => Some(v),
+            Err(_) => {
+                tracing::warn!("ignoring invalid allowed origin {:?}", o);
+                None
+            }
+        })
+        .collect();
+    if origins.is_empty() {
+        return None;
+    }
+    Some(
+        CorsLayer::new()
+            .allow_origin(AllowOrigin::list(origins))
+            .allow_methods([Method::GET, Method::POST, Method::PUT, Method::PATCH, Method::DELETE])
+            .allow_headers([header::AUTHORIZATION, header::CONTENT_TYPE]),
+    )
+}
+
+/// Whether a request may modify data. Login is a POST but only issues a
+/// token, so it stays open in read-only mode.
+fn is_write(method: &Method, path: &str) -> bool {
+    ![Method::GET, Method::HEAD, Method::OPTIONS].contains(method) && path != "/auth/login"
+}
+
+/// `--read-only`: answer writes with 403 READ_ONLY before any handler runs.
+async fn reject_writes(request: Request, next: Next) -> Response {
+    if is_write(request.method(), request.uri().path()) {
+        return openerp_core::ServiceError::ReadOnly("server is in read-only mode".into())
+            .into_response();
+    }
+    next.run(request).await
+}
+
+/// Add browser hardening headers to every response, errors included.
+async fn security_headers(request: Request, next: Next) -> Response {
+    let mut response = next.run(request).await;
+    let headers = response.headers_mut();
+    headers.insert(header::X_CONTENT_TYPE_OPTIONS, HeaderValue::from_static("nosniff"));
+    headers.insert(header::X_FRAME_OPTIONS, HeaderValue::from_static("DENY"));
+    headers.insert(
+        header::REFERRER_POLICY,
+        HeaderValue::from_static("strict-origin-when-cross-origin"),
+    );
+    response
+}
+
+/// Log one line per request with the client IP, for audit trails.
+///
+/// The IP comes from `ConnectInfo`, so the server must be run with
+/// `into_make_service_with_connect_info::<SocketAddr>()`.
+async fn access_log(request: Request, next: Next) -> Response {
+    let remote = request
+        .extensions()
+        .get::<ConnectInfo<SocketAddr>>()
+        .map(|ConnectInfo(addr)| addr.ip().to_string())
+        .unwrap_or_else(|| "-".to_string());
+    let method = request.method().clone();
+    let path = request.uri().path().to_string();
+    let trace = request
+        .headers()
+        .get("traceparent")
+        .and_then(|v| v.to_str().ok())
+        .and_then(trace_id)
+        .map(|id| format!(" trace={id}"))
+        .unwrap_or_default();
+    let start = std::time::Instant::now();
+
+    let response = next.run(request).await;
+    tracing::info!(
+        target: "openerpd::access",
+        "{} {} {} {} {}ms{}",
+        remote,
+        method,
+        path,
+        response.status().as_u16(),
+        start.elapsed().as_millis(),
+        trace,
+    );
+    response
+}
+
+/// Trace ID of a W3C `traceparent` (`00-<32 hex>-<16 hex>-<2 hex>`), so
+/// access log lines can be joined with the caller's trace. Malformed or
+/// all-zero values are ignored rather than copied into the log.
+fn trace_id(traceparent: &str) -> Option<&str> {
+    let parts: Vec<&str> = traceparent.split('-').collect();
+    let [version, trace, span, flags] = parts[..] else {
+        return None;
+    };
+    let hex = |s: &str, len: usize| s.len() == len && s.bytes().all(|b| b.is_ascii_hexdigit());
+    let valid = hex(version, 2)
+        && hex(trace, 32)
+        && hex(span, 16)
+        && hex(flags, 2)
+        && trace.bytes().any(|b| b != b'0');
+    valid.then_some(trace)
 }
 
 async fn index_page() -> impl IntoResponse {
@@ -77,8 +204,29 @@ async fn dashboard_page() -> impl IntoResponse {
     Html(openerp_web::dashboard_html())
 }
 
-async fn health() -> impl IntoResponse {
-    axum::Json(serde_json::json!({"status": "ok"}))
+/// Liveness + storage probe. Returns 503 `degraded` when the data
+/// directory is gone or the KV store cannot be read.
+async fn health(State(state): State<AppState>) -> impl IntoResponse {
+    let data_dir = std::path::Path::new(&state.server_config.storage.data_dir);
+    // A read in the auth Role store: it only has to succeed, the role
+    // need not be there.
+    let root_role_key = format!("auth:role:{}", crate::bootstrap::ROOT_ROLE_ID);
+    let storage_ok = data_dir.is_dir() && state.kv.get(&root_role_key).is_ok();
+
+    if storage_ok {
+        (
+            StatusCode::OK,
+            axum::Json(serde_json::json!({
+                "status": "ok",
+                "version": env!("CARGO_PKG_VERSION"),
+            })),
+        )
+    } else {
+        (
+            StatusCode::SERVICE_UNAVAILABLE,
+            axum::Json(serde_json::json!({"status": "degraded"})),
+        )
+    }
 }
 
 async fn version() -> impl IntoResponse {
@@ -87,3 +235,116 @@ async fn version() -> impl IntoResponse {
         "version": env!("CARGO_PKG_VERSION"),
     }))
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_trace_id() {
+        let id = "4bf92f3577b34da6a3ce929d0e0e4736";
+        assert_eq!(trace_id(&format!("00-{id}-00f067aa0ba902b7-01")), Some(id));
+        assert_eq!(trace_id("00-00000000000000000000000000000000-00f067aa0ba902b7-01"), None);
+        assert_eq!(trace_id(&format!("00-{id}-00f067aa0ba902b7")), None);
+        assert_eq!(trace_id(&format!("00-{id}-short-01")), None);
+        assert_eq!(trace_id("00-not hex\n-00f067aa0ba902b7-01"), None);
+    }
+
+    /// Reads of keys under `readable` succeed (as missing); everything
+    /// else fails, like storage that has gone away.
+    struct TestKv {
+        readable: &'static str,
+    }
+
+    impl openerp_kv::KVStore for TestKv {
+        fn get(&self, key: &str) -> Result<Option<Vec<u8>>, openerp_kv::KVError> {
+            if key.starts_with(self.readable) {
+                return Ok(None);
+            }
+            Err(openerp_kv::KVError::Storage(format!("cannot read {key}")))
+        }
+        fn set(&self, key: &str, _value: &[u8]) -> Result<(), openerp_kv::KVError> {
+            Err(openerp_kv::KVError::Storage(format!("cannot write {key}")))
+        }
+        fn delete(&self, key: &str) -> Result<(), openerp_kv::KVError> {
+            Err(openerp_kv::KVError::Storage(format!("cannot write {key}")))
+        }
+        fn scan(&self, prefix: &str) -> Result<Vec<(String, Vec<u8>)>, openerp_kv::KVError> {
+            Err(openerp_kv::KVError::Storage(format!("cannot scan {prefix}")))
+        }
+        fn batch_set(&self, _entries: &[(&str, &[u8])]) -> Result<(), openerp_kv::KVError> {
+            Err(openerp_kv::KVError::Storage("cannot write".into()))
+        }
+        fn batch_delete(&self, _keys: &[&str]) -> Result<(), openerp_kv::KVError> {
+            Err(openerp_kv::KVError::Storage("cannot write".into()))
+        }
+        fn update(
+            &self,
+            key: &str,
+            _f: &mut dyn FnMut(Option<&[u8]>) -> openerp_kv::Update,
+        ) -> Result<(), openerp_kv::KVError> {
+            Err(openerp_kv::KVError::Storage(format!("cannot write {key}")))
+        }
+        fn is_readonly(&self, _key: &str) -> bool {
+            false
+        }
+    }
+
+    fn test_state(kv: TestKv) -> AppState {
+        AppState {
+            jwt_state: Arc::new(JwtState {
+                decoding_key: jsonwebtoken::DecodingKey::from_secret(b"test"),
+                validation: jsonwebtoken::Validation::default(),
+            }),
+            server_config: Arc::new(crate::config::ServerConfig {
+                root: crate::config::RootConfig {
+                    password_hash: String::new(),
+                },
+                storage: crate::config::StorageConfig {
+                    data_dir: std::env::temp_dir().to_string_lossy().into_owned(),
+                },
+                jwt: crate::config::JwtConfig {
+                    secret: "test".to_string(),
+                    expire_secs: 3600,
+                    leeway_secs: 60,
+                },
+                http: Default::default(),
+            }),
+            kv: Arc::new(kv),
+            read_only: false,
+        }
+    }
+
+    async fn health_of(kv: TestKv) -> (StatusCode, serde_json::Value) {
+        let resp = health(State(test_state(kv))).await.into_response();
+        let status = resp.status();
+        let body = axum::body::to_bytes(resp.into_body(), 1024).await.unwrap();
+        (status, serde_json::from_slice(&body).unwrap())
+    }
+
+    #[tokio::test]
+    async fn test_health_probes_role_store() {
+        let (status, body) = health_of(TestKv { readable: "auth:role:" }).await;
+        assert_eq!(status, StatusCode::OK);
+        assert_eq!(body["status"], "ok");
+    }
+
+    #[tokio::test]
+    async fn test_health_degraded_when_storage_unreadable() {
+        let (status, body) = health_of(TestKv { readable: "nothing:" }).await;
+        assert_eq!(status, StatusCode::SERVICE_UNAVAILABLE);
+        assert_eq!(body["status"], "degraded");
+    }
+
+    #[test]
+    fn test_is_write() {
+        assert!(!is_write(&Method::GET, "/admin/auth/users"));
+        assert!(!is_write(&Method::HEAD, "/health"));
+        assert!(!is_write(&Method::OPTIONS, "/admin/auth/users"));
+        assert!(!is_write(&Method::POST, "/auth/login"));
+        assert!(is_write(&Method::POST, "/admin/auth/users"));
+        assert!(is_write(&Method::PATCH, "/admin/auth/users/x"));
+        assert!(is_write(&Method::PUT, "/admin/auth/users/x"));
+        assert!(is_write(&Method::DELETE, "/admin/auth/users/x"));
+    }
+}
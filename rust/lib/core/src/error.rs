@@ -20,6 +20,7 @@ pub mod error_code {
     pub const READ_ONLY: &str = "READ_ONLY";
     pub const INTERNAL: &str = "INTERNAL";
     pub const STORAGE_ERROR: &str = "STORAGE_ERROR";
+    pub const PAYLOAD_TOO_LARGE: &str = "PAYLOAD_TOO_LARGE";
 }
 
 // ── ServiceError ────────────────────────────────────────────────────
@@ -58,6 +59,10 @@ pub enum ServiceError {
     #[error("{0}")]
     ReadOnly(String),
 
+    /// Request body over the server's size limit. HTTP 413.
+    #[error("{0}")]
+    PayloadTooLarge(String),
+
     /// Storage backend failure. HTTP 500.
     #[error("{0}")]
     Storage(String),
@@ -77,6 +82,7 @@ impl ServiceError {
             ServiceError::Unauthorized(_) => error_code::UNAUTHENTICATED,
             ServiceError::PermissionDenied(_) => error_code::PERMISSION_DENIED,
             ServiceError::ReadOnly(_) => error_code::READ_ONLY,
+            ServiceError::PayloadTooLarge(_) => error_code::PAYLOAD_TOO_LARGE,
             ServiceError::Storage(_) => error_code::STORAGE_ERROR,
             ServiceError::Internal(_) => error_code::INTERNAL,
         }
@@ -91,12 +97,25 @@ impl ServiceError {
             ServiceError::Unauthorized(_) => StatusCode::UNAUTHORIZED,
             ServiceError::PermissionDenied(_) => StatusCode::FORBIDDEN,
             ServiceError::ReadOnly(_) => StatusCode::FORBIDDEN,
+            ServiceError::PayloadTooLarge(_) => StatusCode::PAYLOAD_TOO_LARGE,
             ServiceError::Storage(_) => StatusCode::INTERNAL_SERVER_ERROR,
             ServiceError::Internal(_) => StatusCode::INTERNAL_SERVER_ERROR,
         }
     }
 }
 
+/// A body that isn't valid JSON for the target type is the client's
+/// fault: 400 VALIDATION_FAILED, not axum's plain-text 400/415/422. A body
+/// over the size limit stays a 413, as PAYLOAD_TOO_LARGE.
+impl From<axum::extract::rejection::JsonRejection> for ServiceError {
+    fn from(e: axum::extract::rejection::JsonRejection) -> Self {
+        if e.status() == StatusCode::PAYLOAD_TOO_LARGE {
+            return ServiceError::PayloadTooLarge(e.body_text());
+        }
+        ServiceError::Validation(e.body_text())
+    }
+}
+
 impl IntoResponse for ServiceError {
     fn into_response(self) -> Response {
         let status = self.status_code();
@@ -120,6 +139,7 @@ mod tests {
         assert_eq!(ServiceError::Unauthorized("x".into()).status_code(), StatusCode::UNAUTHORIZED);
         assert_eq!(ServiceError::PermissionDenied("x".into()).status_code(), StatusCode::FORBIDDEN);
         assert_eq!(ServiceError::ReadOnly("x".into()).status_code(), StatusCode::FORBIDDEN);
+        assert_eq!(ServiceError::PayloadTooLarge("x".into()).status_code(), StatusCode::PAYLOAD_TOO_LARGE);
         assert_eq!(ServiceError::Storage("x".into()).status_code(), StatusCode::INTERNAL_SERVER_ERROR);
         assert_eq!(ServiceError::Internal("x".into()).status_code(), StatusCode::INTERNAL_SERVER_ERROR);
     }
@@ -132,6 +152,7 @@ mod tests {
         assert_eq!(ServiceError::Unauthorized("x".into()).error_code(), "UNAUTHENTICATED");
         assert_eq!(ServiceError::PermissionDenied("x".into()).error_code(), "PERMISSION_DENIED");
         assert_eq!(ServiceError::ReadOnly("x".into()).error_code(), "READ_ONLY");
+        assert_eq!(ServiceError::PayloadTooLarge("x".into()).error_code(), "PAYLOAD_TOO_LARGE");
         assert_eq!(ServiceError::Storage("x".into()).error_code(), "STORAGE_ERROR");
         assert_eq!(ServiceError::Internal("x".into()).error_code(), "INTERNAL");
     }
@@ -18,6 +18,10 @@ pub struct ListParams {
     /// Search query string (for full-text search).
     #[serde(default)]
     pub q: Option<String>,
+
+    /// Keyset cursor: only return records whose key sorts after this one.
+    #[serde(default)]
+    pub after: Option<String>,
 }
 
 fn default_limit() -> usize {
@@ -31,6 +35,7 @@ impl Default for ListParams {
             offset: 0,
             sort: None,
             q: None,
+            after: None,
         }
     }
 }
@@ -61,9 +66,10 @@ pub fn new_id() -> String {
     uuid::Uuid::new_v4().to_string().replace('-', "")
 }
 
-/// Get the current time as an RFC 3339 string.
+/// Get the current time as an RFC 3339 string in UTC, with a `Z` suffix
+/// rather than `+00:00`.
 pub fn now_rfc3339() -> String {
-    chrono::Utc::now().to_rfc3339()
+    chrono::Utc::now().to_rfc3339_opts(chrono::SecondsFormat::AutoSi, true)
 }
 
 /// Merge a JSON patch into a base value.
@@ -111,6 +117,8 @@ mod tests {
     fn test_now_rfc3339() {
         let ts = now_rfc3339();
         assert!(ts.contains('T'));
+        assert!(ts.ends_with('Z'), "expected UTC with Z suffix: {}", ts);
+        assert!(chrono::DateTime::parse_from_rfc3339(&ts).is_ok());
     }
 
     #[test]
@@ -419,7 +419,7 @@ mod tests {
         let id = created["id"].as_str().unwrap();
 
         let (s, _) = api(&r, "DELETE", &format!("/widgets/{}", id), None).await;
-        assert_eq!(s, StatusCode::OK);
+        assert_eq!(s, StatusCode::NO_CONTENT);
 
         let (s, _) = api(&r, "GET", &format!("/widgets/{}", id), None).await;
         assert_eq!(s, StatusCode::NOT_FOUND);
@@ -519,7 +519,7 @@ mod tests {
         assert_eq!(fetched["quantity"], 100);
 
         let (s, _) = api(&r, "DELETE", "/items/us-east/ABC-001", None).await;
-        assert_eq!(s, StatusCode::OK);
+        assert_eq!(s, StatusCode::NO_CONTENT);
 
         let (s, _) = api(&r, "GET", "/items/us-east/ABC-001", None).await;
         assert_eq!(s, StatusCode::NOT_FOUND);
@@ -832,4 +832,41 @@ mod tests {
         assert_eq!(s, StatusCode::OK);
         assert!(json["count"].is_number(), "count should have count number");
     }
+
+    // =====================================================================
+    // 26. @group_by on the SQL router
+    // =====================================================================
+
+    #[tokio::test]
+    async fn sql_group_by() {
+        let (ops, _dir) = make_sql_ops::<SqlWidget>();
+        let r = widget_router(ops);
+
+        for (i, active) in [true, false, true, true].into_iter().enumerate() {
+            api(
+                &r,
+                "POST",
+                "/widgets",
+                Some(serde_json::json!({"id": format!("g{}", i), "label": "W", "count": i, "active": active})),
+            )
+            .await;
+        }
+
+        let (s, json) = api(&r, "GET", "/widgets/@group_by?field=active", None).await;
+        assert_eq!(s, StatusCode::OK);
+        assert_eq!(json["field"], "active");
+        assert_eq!(json["buckets"], serde_json::json!([
+            {"value": true, "count": 3},
+            {"value": false, "count": 1},
+        ]));
+        assert_eq!(json["hasMore"], false);
+
+        let (_, json) = api(&r, "GET", "/widgets/@group_by?field=count&limit=2", None).await;
+        assert_eq!(json["buckets"].as_array().unwrap().len(), 2);
+        assert_eq!(json["hasMore"], true);
+
+        let (s, err) = api(&r, "GET", "/widgets/@group_by", None).await;
+        assert_eq!(s, StatusCode::BAD_REQUEST);
+        assert_eq!(err["code"], "VALIDATION_FAILED");
+    }
 }
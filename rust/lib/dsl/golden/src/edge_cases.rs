@@ -275,7 +275,7 @@ mod tests {
 
         // Delete.
         let (s, _) = call(&router, "DELETE", &format!("/records/{}", id), None).await;
-        assert_eq!(s, StatusCode::OK);
+        assert_eq!(s, StatusCode::NO_CONTENT);
         let (s, _) = call(&router, "GET", &format!("/records/{}", id), None).await;
         assert_eq!(s, StatusCode::NOT_FOUND);
     }
@@ -455,7 +455,7 @@ mod tests {
         // Delete all.
         for id in &ids {
             let (s, _) = call(&router, "DELETE", &format!("/items/{}", id), None).await;
-            assert_eq!(s, StatusCode::OK);
+            assert_eq!(s, StatusCode::NO_CONTENT);
         }
 
         // List should be empty.
@@ -574,6 +574,14 @@ mod tests {
         assert_eq!(s, StatusCode::OK);
         assert_eq!(fetched["name"].as_str().unwrap().len(), 10_000);
         assert_eq!(fetched["tags"].as_array().unwrap().len(), 100);
+
+        // 10 000 characters is the store's per-field limit.
+        let (s, err) = call(&router, "POST", "/records",
+            Some(serde_json::json!({ "name": "A".repeat(10_001), "displayName": "Too Long" })),
+        ).await;
+        assert_eq!(s, StatusCode::BAD_REQUEST);
+        assert_eq!(err["code"], "VALIDATION_FAILED");
+        assert!(err["message"].as_str().unwrap().contains("'name'"), "{}", err);
     }
 
     // =====================================================================
@@ -810,7 +818,7 @@ mod tests {
 
         // Delete.
         let (s, _) = call(&router, "DELETE", "/items/reuse-me", None).await;
-        assert_eq!(s, StatusCode::OK);
+        assert_eq!(s, StatusCode::NO_CONTENT);
 
         // Verify deleted.
         let (s, _) = call(&router, "GET", "/items/reuse-me", None).await;
@@ -792,7 +792,7 @@ mod tests {
         let req = Request::builder()
             .method("DELETE").uri(format!("/items/{}", id)).body(Body::empty()).unwrap();
         let resp = router.clone().oneshot(req).await.unwrap();
-        assert_eq!(resp.status(), StatusCode::OK);
+        assert_eq!(resp.status(), StatusCode::NO_CONTENT);
 
         // 6. GET after delete → 404.
         let req = Request::builder()
@@ -878,6 +878,20 @@ mod tests {
         let err: serde_json::Value = serde_json::from_slice(&body).unwrap();
         assert_eq!(err["code"], "ALREADY_EXISTS");
         assert!(err["message"].as_str().unwrap().contains("already exists"));
+
+        // Body over axum's default 2 MB limit → 413 on every write method.
+        let big = format!(r#"{{"id":"auto-id","count":1,"displayName":"{}"}}"#, "x".repeat(3 * 1024 * 1024));
+        for (method, uri) in [("POST", "/widgets"), ("PUT", "/widgets/auto-id"), ("PATCH", "/widgets/auto-id")] {
+            let req = Request::builder()
+                .method(method).uri(uri)
+                .header("content-type", "application/json")
+                .body(Body::from(big.clone())).unwrap();
+            let resp = router.clone().oneshot(req).await.unwrap();
+            assert_eq!(resp.status(), StatusCode::PAYLOAD_TOO_LARGE, "{} {}", method, uri);
+            let body = axum::body::to_bytes(resp.into_body(), 1024 * 1024).await.unwrap();
+            let err: serde_json::Value = serde_json::from_slice(&body).unwrap();
+            assert_eq!(err["code"], "PAYLOAD_TOO_LARGE");
+        }
     }
 
     // =====================================================================
@@ -1142,6 +1156,60 @@ mod tests {
         assert_eq!(result["count"], 3);
     }
 
+    // =====================================================================
+    // Golden: @group_by endpoint via admin router
+    // =====================================================================
+
+    #[tokio::test]
+    async fn golden_admin_group_by() {
+        use axum::body::Body;
+        use axum::http::{Request, StatusCode};
+        use tower::ServiceExt;
+        use openerp_store::admin_kv_router;
+
+        let dir = tempfile::tempdir().unwrap();
+        let kv: Arc<dyn openerp_kv::KVStore> = Arc::new(
+            openerp_kv::RedbStore::open(&dir.path().join("group_by.redb")).unwrap(),
+        );
+        let auth: Arc<dyn openerp_core::Authenticator> = Arc::new(openerp_core::AllowAll);
+        let router = admin_kv_router(KvOps::<Item>::new(kv), auth, "test", "items", "item");
+
+        // 3 × "ok", 2 × "bad".
+        for status in ["ok", "bad", "ok", "bad", "ok"] {
+            let body = serde_json::json!({"widgetId": "w1", "quantity": 1, "status": status});
+            let req = Request::builder()
+                .method("POST").uri("/items")
+                .header("content-type", "application/json")
+                .body(Body::from(serde_json::to_string(&body).unwrap())).unwrap();
+            router.clone().oneshot(req).await.unwrap();
+        }
+
+        let req = Request::builder().uri("/items/@group_by?field=status").body(Body::empty()).unwrap();
+        let resp = router.clone().oneshot(req).await.unwrap();
+        assert_eq!(resp.status(), StatusCode::OK);
+        let body = axum::body::to_bytes(resp.into_body(), 1024 * 1024).await.unwrap();
+        let result: serde_json::Value = serde_json::from_slice(&body).unwrap();
+        assert_eq!(result["field"], "status");
+        assert_eq!(result["buckets"], serde_json::json!([
+            {"value": "ok", "count": 3},
+            {"value": "bad", "count": 2},
+        ]));
+        assert_eq!(result["hasMore"], false);
+
+        // limit caps the buckets, largest first.
+        let req = Request::builder().uri("/items/@group_by?field=status&limit=1").body(Body::empty()).unwrap();
+        let resp = router.clone().oneshot(req).await.unwrap();
+        let body = axum::body::to_bytes(resp.into_body(), 1024 * 1024).await.unwrap();
+        let result: serde_json::Value = serde_json::from_slice(&body).unwrap();
+        assert_eq!(result["buckets"], serde_json::json!([{"value": "ok", "count": 3}]));
+        assert_eq!(result["hasMore"], true);
+
+        // Missing field parameter → 400.
+        let req = Request::builder().uri("/items/@group_by").body(Body::empty()).unwrap();
+        let resp = router.clone().oneshot(req).await.unwrap();
+        assert_eq!(resp.status(), StatusCode::BAD_REQUEST);
+    }
+
     // =====================================================================
     // Golden: Optimistic locking (rev) via admin PUT → 409
     // =====================================================================
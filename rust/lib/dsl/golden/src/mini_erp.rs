@@ -935,7 +935,7 @@ mod tests {
 
         // only-delete: can read + delete but NOT create/update.
         let (s, _) = api_call(&erp.km_router, "DELETE", &format!("/documents/{}", doc_id), None, "only-delete").await;
-        assert_eq!(s, StatusCode::OK, "only-delete can delete");
+        assert_eq!(s, StatusCode::NO_CONTENT, "only-delete can delete");
     }
 
     // ── 15. Cross-module permission isolation ──
@@ -1072,7 +1072,7 @@ mod tests {
 
         // Delete.
         let (s, _) = api_call(&erp.org_router, "DELETE", &format!("/companies/{}", id), None, "root").await;
-        assert_eq!(s, StatusCode::OK);
+        assert_eq!(s, StatusCode::NO_CONTENT);
         let (s, _) = api_call(&erp.org_router, "GET", &format!("/companies/{}", id), None, "root").await;
         assert_eq!(s, StatusCode::NOT_FOUND);
     }
@@ -4,15 +4,19 @@
 //! Permissions follow `{module}:{resource}:{action}` format.
 //! Authentication is delegated to the `Authenticator` trait.
 
+use std::collections::hash_map::Entry;
+use std::collections::HashMap;
 use std::sync::Arc;
 
+use axum::extract::rejection::JsonRejection;
 use axum::extract::{Path, Query, State};
-use axum::http::HeaderMap;
+use axum::http::{header, HeaderMap, StatusCode};
+use axum::response::{IntoResponse, Response};
 use axum::routing::get;
 use axum::{Json, Router};
 use openerp_core::{Authenticator, CountResult, ListParams, ListResult, ServiceError};
 use serde::de::DeserializeOwned;
-use serde::Serialize;
+use serde::{Deserialize, Serialize};
 
 use crate::kv::{KvOps, KvStore};
 use crate::sql::{SqlOps, SqlStore};
@@ -32,7 +36,8 @@ struct AdminState<T: KvStore + DslModel> {
 ///   GET    /{resources}         — list (paginated)
 ///   POST   /{resources}         — create
 ///   GET    /{resources}/@count  — count (optional)
-///   GET    /{resources}/{id}    — get by key
+///   GET    /{resources}/@group_by?field=F — per-value counts of field F
+///   GET    /{resources}/{id}    — get by key (Last-Modified / If-Modified-Since)
 ///   PUT    /{resources}/{id}    — full update (with updatedAt check)
 ///   PATCH  /{resources}/{id}    — partial update (RFC 7386 merge patch)
 ///   DELETE /{resources}/{id}    — delete
@@ -55,11 +60,13 @@ pub fn admin_kv_router<T: KvStore + DslModel + Serialize + DeserializeOwned>(
 
     let list_path = format!("/{}", resource_path);
     let count_path = format!("/{}/@count", resource_path);
+    let group_by_path = format!("/{}/@group_by", resource_path);
     let item_path = format!("/{}/{{id}}", resource_path);
 
     Router::new()
         .route(&list_path, get(list_handler::<T>).post(create_handler::<T>))
         .route(&count_path, get(count_handler::<T>))
+        .route(&group_by_path, get(group_by_handler::<T>))
         .route(
             &item_path,
             get(get_handler::<T>)
@@ -113,11 +120,112 @@ async fn count_handler<T: KvStore + DslModel + Serialize>(
     Ok(Json(CountResult { count }))
 }
 
+/// Upper bound on `@group_by` buckets returned in one response.
+const MAX_GROUP_BUCKETS: usize = 1000;
+
+/// Query for `@group_by`: `field` is the camelCase JSON field name;
+/// `limit` (default 50, at most [`MAX_GROUP_BUCKETS`]) and `offset` page
+/// through the buckets like `limit`/`offset` page through a list.
+#[derive(Debug, Deserialize)]
+struct GroupByParams {
+    field: Option<String>,
+    #[serde(default = "default_group_limit")]
+    limit: usize,
+    #[serde(default)]
+    offset: usize,
+}
+
+fn default_group_limit() -> usize {
+    50
+}
+
+async fn group_by_handler<T: KvStore + DslModel + Serialize>(
+    State(state): State<Arc<AdminState<T>>>,
+    headers: HeaderMap,
+    Query(params): Query<GroupByParams>,
+) -> Result<Json<serde_json::Value>, ServiceError> {
+    let p = perm(&state.module, &state.resource, "list");
+    state.auth.check(&headers, &p)?;
+
+    let field = group_by_field::<T>(params.field.as_deref())?;
+    group_by_response(&state.ops.list()?, field, &params)
+}
+
+/// Validate the `field` of a `@group_by` query: required, and never a
+/// hidden field (that would leak its values through the bucket keys).
+fn group_by_field<T: DslModel>(field: Option<&str>) -> Result<String, ServiceError> {
+    let field = field
+        .filter(|f| !f.is_empty())
+        .map(str::to_string)
+        .ok_or_else(|| ServiceError::Validation("missing 'field' query parameter".into()))?;
+    if get_hidden_fields(&T::__dsl_ir()).contains(&field) {
+        return Err(ServiceError::Validation(format!(
+            "field '{}' cannot be grouped by", field
+        )));
+    }
+    Ok(field)
+}
+
+fn group_by_response<T: Serialize>(
+    records: &[T],
+    field: String,
+    params: &GroupByParams,
+) -> Result<Json<serde_json::Value>, ServiceError> {
+    let records = records
+        .iter()
+        .map(serde_json::to_value)
+        .collect::<Result<Vec<_>, _>>()
+        .map_err(|e| ServiceError::Internal(format!("Serialization error: {}", e)))?;
+    let limit = params.limit.min(MAX_GROUP_BUCKETS);
+    let (buckets, has_more) = group_buckets(&records, &field, params.offset, limit);
+
+    Ok(Json(serde_json::json!({
+        "field": field,
+        "buckets": buckets,
+        "hasMore": has_more,
+    })))
+}
+
+/// Group records by the value of `field` (missing → `null`).
+///
+/// Buckets are `{"value", "count"}`, largest first (ties keep first-seen
+/// order). Returns the `limit` buckets after `offset`, and whether more
+/// follow them.
+fn group_buckets(
+    records: &[serde_json::Value],
+    field: &str,
+    offset: usize,
+    limit: usize,
+) -> (Vec<serde_json::Value>, bool) {
+    // serde_json::Value isn't Hash; its canonical JSON text is.
+    let mut index: HashMap<String, usize> = HashMap::new();
+    let mut buckets: Vec<(serde_json::Value, usize)> = Vec::new();
+    for record in records {
+        let value = record.get(field).cloned().unwrap_or(serde_json::Value::Null);
+        match index.entry(value.to_string()) {
+            Entry::Occupied(slot) => buckets[*slot.get()].1 += 1,
+            Entry::Vacant(slot) => {
+                slot.insert(buckets.len());
+                buckets.push((value, 1));
+            }
+        }
+    }
+    buckets.sort_by(|a, b| b.1.cmp(&a.1));
+    let has_more = buckets.len() > offset.saturating_add(limit);
+    let buckets = buckets
+        .into_iter()
+        .skip(offset)
+        .take(limit)
+        .map(|(value, count)| serde_json::json!({"value": value, "count": count}))
+        .collect();
+    (buckets, has_more)
+}
+
 async fn get_handler<T: KvStore + DslModel + Serialize>(
     State(state): State<Arc<AdminState<T>>>,
     Path(id): Path<String>,
     headers: HeaderMap,
-) -> Result<Json<serde_json::Value>, ServiceError> {
+) -> Result<Response, ServiceError> {
     let p = perm(&state.module, &state.resource, "read");
     state.auth.check(&headers, &p)?;
 
@@ -126,16 +234,42 @@ async fn get_handler<T: KvStore + DslModel + Serialize>(
     let hidden_fields = get_hidden_fields(&ir);
     let filtered = secure_serialize(&record, &hidden_fields)
         .map_err(|e| ServiceError::Internal(format!("Serialization error: {}", e)))?;
-    Ok(Json(filtered))
+
+    // updatedAt doubles as Last-Modified (HTTP dates have 1s resolution).
+    let Some(modified) = filtered["updatedAt"]
+        .as_str()
+        .and_then(|s| chrono::DateTime::parse_from_rfc3339(s).ok())
+    else {
+        return Ok(Json(filtered).into_response());
+    };
+    if not_modified_since(&headers, modified.timestamp()) {
+        return Ok(StatusCode::NOT_MODIFIED.into_response());
+    }
+    let last_modified = modified
+        .with_timezone(&chrono::Utc)
+        .format("%a, %d %b %Y %H:%M:%S GMT")
+        .to_string();
+    Ok(([(header::LAST_MODIFIED, last_modified)], Json(filtered)).into_response())
+}
+
+/// True if `If-Modified-Since` is present and not older than `modified`
+/// (unix seconds). Unparseable dates are ignored, per RFC 9110.
+fn not_modified_since(headers: &HeaderMap, modified: i64) -> bool {
+    headers
+        .get(header::IF_MODIFIED_SINCE)
+        .and_then(|v| v.to_str().ok())
+        .and_then(|s| chrono::DateTime::parse_from_rfc2822(s).ok())
+        .is_some_and(|since| modified <= since.timestamp())
 }
 
 async fn create_handler<T: KvStore + DslModel + Serialize + DeserializeOwned>(
     State(state): State<Arc<AdminState<T>>>,
     headers: HeaderMap,
-    Json(record): Json<T>,
+    body: Result<Json<T>, JsonRejection>,
 ) -> Result<Json<serde_json::Value>, ServiceError> {
     let p = perm(&state.module, &state.resource, "create");
     state.auth.check(&headers, &p)?;
+    let Json(record) = body?;
 
     let created = state.ops.save_new(record)?;
     let ir = T::__dsl_ir();
@@ -149,10 +283,11 @@ async fn update_handler<T: KvStore + DslModel + Serialize + DeserializeOwned>(
     State(state): State<Arc<AdminState<T>>>,
     Path(id): Path<String>,
     headers: HeaderMap,
-    Json(record): Json<T>,
+    body: Result<Json<T>, JsonRejection>,
 ) -> Result<Json<serde_json::Value>, ServiceError> {
     let p = perm(&state.module, &state.resource, "update");
     state.auth.check(&headers, &p)?;
+    let Json(record) = body?;
 
     let body_key = record.key_value();
     if body_key != id {
@@ -160,6 +295,7 @@ async fn update_handler<T: KvStore + DslModel + Serialize + DeserializeOwned>(
             "URL key '{}' does not match body key '{}'", id, body_key
         )));
     }
+    T::check_write(&id)?;
     let existing = state.ops.get_or_err(&id)?;
     let ir = T::__dsl_ir();
     let hidden_fields = get_hidden_fields(&ir);
@@ -176,10 +312,11 @@ async fn patch_handler<T: KvStore + DslModel + Serialize + DeserializeOwned>(
     State(state): State<Arc<AdminState<T>>>,
     Path(id): Path<String>,
     headers: HeaderMap,
-    Json(patch): Json<serde_json::Value>,
+    body: Result<Json<serde_json::Value>, JsonRejection>,
 ) -> Result<Json<serde_json::Value>, ServiceError> {
     let p = perm(&state.module, &state.resource, "update");
     state.auth.check(&headers, &p)?;
+    let Json(patch) = body?;
 
     let patched = state.ops.patch(&id, &patch)?;
     let ir = T::__dsl_ir();
@@ -193,12 +330,12 @@ async fn delete_handler<T: KvStore + DslModel + Serialize>(
     State(state): State<Arc<AdminState<T>>>,
     Path(id): Path<String>,
     headers: HeaderMap,
-) -> Result<(), ServiceError> {
+) -> Result<StatusCode, ServiceError> {
     let p = perm(&state.module, &state.resource, "delete");
     state.auth.check(&headers, &p)?;
 
     state.ops.delete(&id)?;
-    Ok(())
+    Ok(StatusCode::NO_CONTENT)
 }
 
 // ── SQL admin router ──
@@ -221,6 +358,7 @@ struct SqlAdminState<T: SqlStore + DslModel> {
 ///   GET    /{resources}              — list (paginated)
 ///   POST   /{resources}              — create
 ///   GET    /{resources}/@count       — count
+///   GET    /{resources}/@group_by?field=F — per-value counts of field F
 ///   GET    /{resources}/{pk...}      — get by PK
 ///   PUT    /{resources}/{pk...}      — full update (with updatedAt check)
 ///   PATCH  /{resources}/{pk...}      — partial update (RFC 7386 merge patch)
@@ -241,6 +379,7 @@ pub fn admin_sql_router<T: SqlStore + DslModel + Serialize + DeserializeOwned>(
 
     let list_path = format!("/{}", resource_path);
     let count_path = format!("/{}/@count", resource_path);
+    let group_by_path = format!("/{}/@group_by", resource_path);
 
     let item_path = if T::PK.len() <= 1 {
         format!("/{}/{{id}}", resource_path)
@@ -254,6 +393,7 @@ pub fn admin_sql_router<T: SqlStore + DslModel + Serialize + DeserializeOwned>(
             get(sql_list_handler::<T>).post(sql_create_handler::<T>),
         )
         .route(&count_path, get(sql_count_handler::<T>))
+        .route(&group_by_path, get(sql_group_by_handler::<T>))
         .route(
             &item_path,
             get(sql_get_handler::<T>)
@@ -315,6 +455,18 @@ async fn sql_count_handler<T: SqlStore + DslModel + Serialize>(
     Ok(Json(CountResult { count }))
 }
 
+async fn sql_group_by_handler<T: SqlStore + DslModel + Serialize>(
+    State(state): State<Arc<SqlAdminState<T>>>,
+    headers: HeaderMap,
+    Query(params): Query<GroupByParams>,
+) -> Result<Json<serde_json::Value>, ServiceError> {
+    let p = perm(&state.module, &state.resource, "list");
+    state.auth.check(&headers, &p)?;
+
+    let field = group_by_field::<T>(params.field.as_deref())?;
+    group_by_response(&state.ops.list()?, field, &params)
+}
+
 async fn sql_get_handler<T: SqlStore + DslModel + Serialize>(
     State(state): State<Arc<SqlAdminState<T>>>,
     Path(pk_path): Path<String>,
@@ -336,10 +488,11 @@ async fn sql_get_handler<T: SqlStore + DslModel + Serialize>(
 async fn sql_create_handler<T: SqlStore + DslModel + Serialize + DeserializeOwned>(
     State(state): State<Arc<SqlAdminState<T>>>,
     headers: HeaderMap,
-    Json(record): Json<T>,
+    body: Result<Json<T>, JsonRejection>,
 ) -> Result<Json<serde_json::Value>, ServiceError> {
     let p = perm(&state.module, &state.resource, "create");
     state.auth.check(&headers, &p)?;
+    let Json(record) = body?;
 
     let created = state.ops.save_new(record)?;
     let ir = T::__dsl_ir();
@@ -353,10 +506,11 @@ async fn sql_update_handler<T: SqlStore + DslModel + Serialize + DeserializeOwne
     State(state): State<Arc<SqlAdminState<T>>>,
     Path(pk_path): Path<String>,
     headers: HeaderMap,
-    Json(record): Json<T>,
+    body: Result<Json<T>, JsonRejection>,
 ) -> Result<Json<serde_json::Value>, ServiceError> {
     let p = perm(&state.module, &state.resource, "update");
     state.auth.check(&headers, &p)?;
+    let Json(record) = body?;
 
     let pks = parse_pk_path(&pk_path, T::PK.len())?;
     let body_pks = record.pk_values();
@@ -382,10 +536,11 @@ async fn sql_patch_handler<T: SqlStore + DslModel + Serialize + DeserializeOwned
     State(state): State<Arc<SqlAdminState<T>>>,
     Path(pk_path): Path<String>,
     headers: HeaderMap,
-    Json(patch): Json<serde_json::Value>,
+    body: Result<Json<serde_json::Value>, JsonRejection>,
 ) -> Result<Json<serde_json::Value>, ServiceError> {
     let p = perm(&state.module, &state.resource, "update");
     state.auth.check(&headers, &p)?;
+    let Json(patch) = body?;
 
     let pks = parse_pk_path(&pk_path, T::PK.len())?;
     let pk_refs: Vec<&str> = pks.iter().map(|s| s.as_str()).collect();
@@ -401,14 +556,14 @@ async fn sql_delete_handler<T: SqlStore + DslModel + Serialize>(
     State(state): State<Arc<SqlAdminState<T>>>,
     Path(pk_path): Path<String>,
     headers: HeaderMap,
-) -> Result<(), ServiceError> {
+) -> Result<StatusCode, ServiceError> {
     let p = perm(&state.module, &state.resource, "delete");
     state.auth.check(&headers, &p)?;
 
     let pks = parse_pk_path(&pk_path, T::PK.len())?;
     let pk_refs: Vec<&str> = pks.iter().map(|s| s.as_str()).collect();
     state.ops.delete(&pk_refs)?;
-    Ok(())
+    Ok(StatusCode::NO_CONTENT)
 }
 
 /// Securely serialize a record, masking hidden field values with `null`.
@@ -496,3 +651,30 @@ fn merge_hidden_fields<T: Serialize + DeserializeOwned>(
 
     serde_json::from_value(new_json)
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn ims(value: &str) -> HeaderMap {
+        let mut headers = HeaderMap::new();
+        headers.insert(header::IF_MODIFIED_SINCE, value.parse().unwrap());
+        headers
+    }
+
+    #[test]
+    fn not_modified_since_compares_seconds() {
+        // Thu, 15 Oct 2026 10:11:12 GMT
+        let t = 1_792_059_072;
+        let headers = ims("Thu, 15 Oct 2026 10:11:12 GMT");
+        assert!(not_modified_since(&headers, t));
+        assert!(not_modified_since(&headers, t - 1));
+        assert!(!not_modified_since(&headers, t + 1));
+    }
+
+    #[test]
+    fn not_modified_since_ignores_missing_or_bad_header() {
+        assert!(!not_modified_since(&HeaderMap::new(), 0));
+        assert!(!not_modified_since(&ims("yesterday"), 0));
+    }
+}
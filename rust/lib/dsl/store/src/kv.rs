@@ -65,6 +65,19 @@ pub trait KvStore: Serialize + DeserializeOwned + Clone + Send + Sync + 'static
 
     /// Called after a record is deleted.
     fn after_delete(&self) {}
+
+    /// Called with the key before a delete, ahead of the existence check.
+    /// Return an error to refuse (e.g. for reserved keys).
+    fn check_delete(_id: &str) -> Result<(), ServiceError> {
+        Ok(())
+    }
+
+    /// Called with the key before a create, update or patch, ahead of the
+    /// existence check. A patch also checks the key of the merged record,
+    /// so it cannot rename a record onto a refused key.
+    fn check_write(_id: &str) -> Result<(), ServiceError> {
+        Ok(())
+    }
 }
 
 /// CRUD operations for a KvStore model. Holds a reference to the KV backend.
@@ -94,6 +107,36 @@ impl<T: KvStore + DslModel> KvOps<T> {
         }
     }
 
+    fn not_found(id: &str) -> ServiceError {
+        ServiceError::NotFound(format!("{} '{}' not found", T::KEY.name, id))
+    }
+
+    /// Read-check-write `key` in one backend transaction: `f` sees the
+    /// stored bytes and either says what to write (plus a result) or
+    /// refuses with an error, in which case nothing is written. Duplicate,
+    /// existence and `updatedAt` checks go in `f`, so no concurrent write
+    /// can slip in between them and the write.
+    fn update_key<R>(
+        &self,
+        key: &str,
+        mut f: impl FnMut(Option<&[u8]>) -> Result<(openerp_kv::Update, R), ServiceError>,
+    ) -> Result<R, ServiceError> {
+        let mut result = None;
+        self.kv
+            .update(key, &mut |current| match f(current) {
+                Ok((update, value)) => {
+                    result = Some(Ok(value));
+                    update
+                }
+                Err(e) => {
+                    result = Some(Err(e));
+                    openerp_kv::Update::Keep
+                }
+            })
+            .map_err(Self::kv_err)?;
+        result.unwrap_or_else(|| Err(ServiceError::Internal("KV update did not run".into())))
+    }
+
     /// Get a record by key value. Returns None if not found.
     pub fn get(&self, id: &str) -> Result<Option<T>, ServiceError> {
         let key = Self::make_key(id);
@@ -109,9 +152,7 @@ impl<T: KvStore + DslModel> KvOps<T> {
 
     /// Get a record or return NotFound error.
     pub fn get_or_err(&self, id: &str) -> Result<T, ServiceError> {
-        self.get(id)?.ok_or_else(|| {
-            ServiceError::NotFound(format!("{} '{}' not found", T::KEY.name, id))
-        })
+        self.get(id)?.ok_or_else(|| Self::not_found(id))
     }
 
     /// List all records with this prefix.
@@ -137,7 +178,11 @@ impl<T: KvStore + DslModel> KvOps<T> {
         &self,
         params: &openerp_core::ListParams,
     ) -> Result<openerp_core::ListResult<T>, ServiceError> {
-        let all = self.list()?;
+        let mut all = self.list()?;
+        // Scan results are in key order, so the cursor is a plain key compare.
+        if let Some(after) = params.after.as_deref().filter(|a| !a.is_empty()) {
+            all.retain(|r| r.key_value().as_str() > after);
+        }
         let total = all.len();
         let offset = params.offset.min(total);
         let end = (offset + params.limit).min(total);
@@ -172,24 +217,25 @@ impl<T: KvStore + DslModel> KvOps<T> {
         Self::check_names(&record)?;
 
         let id = record.key_value();
+        T::check_write(&id)?;
         let key = Self::make_key(&id);
 
-        if self.kv.get(&key).map_err(Self::kv_err)?.is_some() {
-            return Err(ServiceError::Conflict(format!(
-                "{} '{}' already exists",
-                T::KEY.name, id
-            )));
-        }
-
         let mut json_val = serde_json::to_value(&record)
             .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
+        crate::limits::check_field_lengths(&json_val)?;
         crate::timestamp::stamp_create(&mut json_val);
         let record: T = serde_json::from_value(json_val)
             .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
 
         let bytes = serde_json::to_vec(&record)
             .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
-        self.kv.set(&key, &bytes).map_err(Self::kv_err)?;
+        self.update_key(&key, |current| match current {
+            Some(_) => Err(ServiceError::Conflict(format!(
+                "{} '{}' already exists",
+                T::KEY.name, id
+            ))),
+            None => Ok((openerp_kv::Update::Set(bytes.clone()), ())),
+        })?;
 
         Ok(record)
     }
@@ -198,41 +244,53 @@ impl<T: KvStore + DslModel> KvOps<T> {
     ///
     /// Compares the incoming record's `updatedAt` with the stored value.
     /// If they don't match, returns `ServiceError::Conflict` (409).
+    /// A record that carries an `updatedAt` but is no longer stored was
+    /// deleted since it was read: NotFound, rather than writing it back.
     /// The store layer sets a fresh `updatedAt` — models don't need to.
     pub fn save(&self, mut record: T) -> Result<T, ServiceError> {
         let id = record.key_value();
+        T::check_write(&id)?;
         let key = Self::make_key(&id);
 
-        let existing_bytes = self.kv.get(&key).map_err(Self::kv_err)?;
-        if let Some(existing_bytes) = &existing_bytes {
-            let existing: serde_json::Value = serde_json::from_slice(existing_bytes)
-                .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
-            let incoming = serde_json::to_value(&record)
-                .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
-
-            let existing_ts = existing.get("updatedAt").and_then(|v| v.as_str()).unwrap_or("");
-            let incoming_ts = incoming.get("updatedAt").and_then(|v| v.as_str()).unwrap_or("");
-
-            if incoming_ts != existing_ts {
-                return Err(ServiceError::Conflict(format!(
-                    "updatedAt mismatch: stored {}, got {}",
-                    existing_ts, incoming_ts
-                )));
-            }
-        }
+        let incoming = serde_json::to_value(&record)
+            .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
+        let incoming_ts = incoming
+            .get("updatedAt")
+            .and_then(|v| v.as_str())
+            .unwrap_or("")
+            .to_string();
 
         record.before_update();
         Self::check_names(&record)?;
 
         let mut json_val = serde_json::to_value(&record)
             .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
+        crate::limits::check_field_lengths(&json_val)?;
         crate::timestamp::stamp_update(&mut json_val);
         let record: T = serde_json::from_value(json_val)
             .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
 
         let bytes = serde_json::to_vec(&record)
             .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
-        self.kv.set(&key, &bytes).map_err(Self::kv_err)?;
+        self.update_key(&key, |current| {
+            match current {
+                Some(existing_bytes) => {
+                    let existing: serde_json::Value = serde_json::from_slice(existing_bytes)
+                        .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
+                    let existing_ts =
+                        existing.get("updatedAt").and_then(|v| v.as_str()).unwrap_or("");
+                    if incoming_ts != existing_ts {
+                        return Err(ServiceError::Conflict(format!(
+                            "updatedAt mismatch: stored {}, got {}",
+                            existing_ts, incoming_ts
+                        )));
+                    }
+                }
+                None if !incoming_ts.is_empty() => return Err(Self::not_found(&id)),
+                None => {}
+            }
+            Ok((openerp_kv::Update::Set(bytes.clone()), ()))
+        })?;
 
         Ok(record)
     }
@@ -244,46 +302,66 @@ impl<T: KvStore + DslModel> KvOps<T> {
     /// optimistic locking — the server returns 409 if it doesn't match.
     /// The store layer sets a fresh `updatedAt` after merge.
     pub fn patch(&self, id: &str, patch: &serde_json::Value) -> Result<T, ServiceError> {
-        let existing = self.get_or_err(id)?;
-        let mut base = serde_json::to_value(&existing)
-            .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
+        // A non-object merge patch replaces the whole record (RFC 7386).
+        if !patch.is_object() {
+            return Err(ServiceError::Validation("merge patch must be a JSON object".into()));
+        }
+        T::check_write(id)?;
+        let key = Self::make_key(id);
+        // The merge depends on the stored record, so it runs inside the
+        // update; it is plain in-memory work.
+        self.update_key(&key, |current| {
+            let existing_bytes = current.ok_or_else(|| Self::not_found(id))?;
+            let existing: T = serde_json::from_slice(existing_bytes)
+                .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
+            let mut base = serde_json::to_value(&existing)
+                .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
 
-        if let Some(patch_ts) = patch.get("updatedAt").and_then(|v| v.as_str()) {
-            let base_ts = base.get("updatedAt").and_then(|v| v.as_str()).unwrap_or("");
-            if patch_ts != base_ts {
-                return Err(ServiceError::Conflict(format!(
-                    "updatedAt mismatch: stored {}, got {}",
-                    base_ts, patch_ts
-                )));
+            if let Some(patch_ts) = crate::timestamp::patch_updated_at(patch)? {
+                let base_ts = base.get("updatedAt").and_then(|v| v.as_str()).unwrap_or("");
+                if patch_ts != base_ts {
+                    return Err(ServiceError::Conflict(format!(
+                        "updatedAt mismatch: stored {}, got {}",
+                        base_ts, patch_ts
+                    )));
+                }
             }
-        }
 
-        openerp_core::merge_patch(&mut base, patch);
+            openerp_core::merge_patch(&mut base, patch);
 
-        let mut record: T = serde_json::from_value(base)
-            .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
-        record.before_update();
-        Self::check_names(&record)?;
+            // The patch is client input: a field of the wrong type is a 400.
+            let mut record: T = serde_json::from_value(base)
+                .map_err(|e| ServiceError::Validation(format!("invalid patch: {}", e)))?;
+            T::check_write(&record.key_value())?;
+            record.before_update();
+            Self::check_names(&record)?;
 
-        let mut json_val = serde_json::to_value(&record)
-            .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
-        crate::timestamp::stamp_update(&mut json_val);
-        let record: T = serde_json::from_value(json_val)
-            .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
-
-        let key = Self::make_key(id);
-        let bytes = serde_json::to_vec(&record)
-            .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
-        self.kv.set(&key, &bytes).map_err(Self::kv_err)?;
+            let mut json_val = serde_json::to_value(&record)
+                .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
+            crate::limits::check_field_lengths(&json_val)?;
+            crate::timestamp::stamp_update(&mut json_val);
+            let record: T = serde_json::from_value(json_val)
+                .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
 
-        Ok(record)
+            let bytes = serde_json::to_vec(&record)
+                .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
+            Ok((openerp_kv::Update::Set(bytes), record))
+        })
     }
 
     /// Delete a record by key value.
     pub fn delete(&self, id: &str) -> Result<(), ServiceError> {
-        let record = self.get_or_err(id)?;
+        T::check_delete(id)?;
         let key = Self::make_key(id);
-        self.kv.delete(&key).map_err(Self::kv_err)?;
+        // Looked up in the same transaction as the delete, so a write that
+        // already passed its own lookup cannot land after it and bring the
+        // record back.
+        let record = self.update_key(&key, |current| {
+            let bytes = current.ok_or_else(|| Self::not_found(id))?;
+            let record: T = serde_json::from_slice(bytes)
+                .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
+            Ok((openerp_kv::Update::Delete, record))
+        })?;
         record.after_delete();
         Ok(())
     }
@@ -322,6 +400,20 @@ mod tests {
                 self.id = "auto-id".to_string();
             }
         }
+
+        fn check_delete(id: &str) -> Result<(), ServiceError> {
+            if id == "locked" {
+                return Err(ServiceError::PermissionDenied("locked".into()));
+            }
+            Ok(())
+        }
+
+        fn check_write(id: &str) -> Result<(), ServiceError> {
+            if id == "reserved" {
+                return Err(ServiceError::PermissionDenied("reserved".into()));
+            }
+            Ok(())
+        }
     }
 
     impl DslModel for Thing {
@@ -415,6 +507,34 @@ mod tests {
             "save_new of existing readonly key should fail with duplicate, got: {}", err);
     }
 
+    #[test]
+    fn check_delete_refuses_before_lookup() {
+        let (ops, _dir) = make_ops();
+        // Refused whether or not the record exists.
+        let err = ops.delete("locked").unwrap_err();
+        assert_eq!(err.error_code(), "PERMISSION_DENIED");
+        ops.save_new(new_thing("locked", "L", 1)).unwrap();
+        assert_eq!(ops.delete("locked").unwrap_err().error_code(), "PERMISSION_DENIED");
+        assert!(ops.get("locked").unwrap().is_some());
+    }
+
+    #[test]
+    fn check_write_refuses_create_update_and_rename() {
+        let (ops, _dir) = make_ops();
+        let err = ops.save_new(new_thing("reserved", "L", 1)).unwrap_err();
+        assert_eq!(err.error_code(), "PERMISSION_DENIED");
+        assert_eq!(ops.save(new_thing("reserved", "L", 1)).unwrap_err().error_code(), "PERMISSION_DENIED");
+        assert_eq!(
+            ops.patch("reserved", &serde_json::json!({"count": 2})).unwrap_err().error_code(),
+            "PERMISSION_DENIED",
+        );
+
+        ops.save_new(new_thing("a", "A", 1)).unwrap();
+        let err = ops.patch("a", &serde_json::json!({"id": "reserved"})).unwrap_err();
+        assert_eq!(err.error_code(), "PERMISSION_DENIED");
+        assert!(ops.get("reserved").unwrap().is_none());
+    }
+
     #[test]
     fn delete_nonexistent_returns_not_found() {
         let (ops, _dir) = make_ops();
@@ -451,6 +571,29 @@ mod tests {
         assert!(!result.has_more);
     }
 
+    #[test]
+    fn list_paginated_after_cursor() {
+        let (ops, _dir) = make_ops();
+        for i in 0..6u32 {
+            ops.save_new(new_thing(&format!("k{}", i), "N", i)).unwrap();
+        }
+
+        let params = openerp_core::ListParams { limit: 3, ..Default::default() };
+        let page1 = ops.list_paginated(&params).unwrap();
+        let ids: Vec<&str> = page1.items.iter().map(|t| t.id.as_str()).collect();
+        assert_eq!(ids, ["k0", "k1", "k2"]);
+
+        let params = openerp_core::ListParams {
+            limit: 3,
+            after: Some("k2".into()),
+            ..Default::default()
+        };
+        let page2 = ops.list_paginated(&params).unwrap();
+        let ids: Vec<&str> = page2.items.iter().map(|t| t.id.as_str()).collect();
+        assert_eq!(ids, ["k3", "k4", "k5"]);
+        assert!(!page2.has_more);
+    }
+
     #[test]
     fn count_returns_total() {
         let (ops, _dir) = make_ops();
@@ -563,6 +706,109 @@ mod tests {
             "Expected updatedAt conflict, got: {}", err);
     }
 
+    #[test]
+    fn concurrent_patches_with_same_updated_at_one_wins() {
+        let (ops, _dir) = make_ops();
+        let ops = Arc::new(ops);
+        let created = ops.save_new(new_thing("p4", "A", 0)).unwrap();
+
+        let handles: Vec<_> = (0..20)
+            .map(|i| {
+                let ops = ops.clone();
+                let patch = serde_json::json!({ "count": i, "updatedAt": created.updated_at });
+                std::thread::spawn(move || ops.patch("p4", &patch))
+            })
+            .collect();
+        let results: Vec<_> = handles.into_iter().map(|h| h.join().unwrap()).collect();
+
+        let winners: Vec<&Thing> = results.iter().filter_map(|r| r.as_ref().ok()).collect();
+        assert_eq!(winners.len(), 1, "exactly one patch should win");
+        for err in results.iter().filter_map(|r| r.as_ref().err()) {
+            assert!(err.to_string().contains("updatedAt mismatch"), "got: {}", err);
+        }
+
+        let stored = ops.get_or_err("p4").unwrap();
+        assert_eq!(stored.count, winners[0].count);
+        assert_eq!(stored.updated_at, winners[0].updated_at);
+    }
+
+    #[test]
+    fn writes_racing_a_delete_never_resurrect_the_record() {
+        let (ops, _dir) = make_ops();
+        let ops = Arc::new(ops);
+        for round in 0..50 {
+            let id = format!("r{}", round);
+            let created = ops.save_new(new_thing(&id, "A", 0)).unwrap();
+
+            let patcher = {
+                let (ops, id) = (ops.clone(), id.clone());
+                std::thread::spawn(move || ops.patch(&id, &serde_json::json!({ "count": 1 })))
+            };
+            let saver = {
+                let ops = ops.clone();
+                let mut stale = created.clone();
+                stale.count = 2;
+                std::thread::spawn(move || ops.save(stale))
+            };
+            ops.delete(&id).unwrap();
+            let _ = patcher.join().unwrap();
+            let _ = saver.join().unwrap();
+
+            assert!(ops.get(&id).unwrap().is_none(), "round {}: deleted record came back", round);
+        }
+    }
+
+    #[test]
+    fn patch_rejects_non_object() {
+        let (ops, _dir) = make_ops();
+        ops.save_new(new_thing("p5", "A", 1)).unwrap();
+        for patch in [serde_json::json!(null), serde_json::json!([1]), serde_json::json!("x")] {
+            let err = ops.patch("p5", &patch).unwrap_err();
+            assert_eq!(err.error_code(), "VALIDATION_FAILED", "{}: {}", patch, err);
+        }
+        assert_eq!(ops.get_or_err("p5").unwrap().name, "A");
+    }
+
+    #[test]
+    fn patch_rejects_bad_updated_at_and_field_types() {
+        let (ops, _dir) = make_ops();
+        ops.save_new(new_thing("p6", "A", 1)).unwrap();
+        for patch in [
+            serde_json::json!({ "name": "X", "updatedAt": "not-a-date" }),
+            serde_json::json!({ "name": "X", "updatedAt": 1.5 }),
+            serde_json::json!({ "name": "X", "updatedAt": null }),
+            serde_json::json!({ "count": "not-a-number" }),
+            serde_json::json!({ "count": -1 }),
+            serde_json::json!({ "count": 1.5 }),
+        ] {
+            let err = ops.patch("p6", &patch).unwrap_err();
+            assert_eq!(err.error_code(), "VALIDATION_FAILED", "{}: {}", patch, err);
+        }
+        let stored = ops.get_or_err("p6").unwrap();
+        assert_eq!((stored.name.as_str(), stored.count), ("A", 1));
+    }
+
+    #[test]
+    fn string_fields_are_capped() {
+        let (ops, _dir) = make_ops();
+        let max = crate::limits::MAX_FIELD_CHARS;
+        // Characters, not bytes: a multi-byte name at the limit is accepted.
+        let at_limit = ops.save_new(new_thing("l1", &"é".repeat(max), 1)).unwrap();
+        assert_eq!(at_limit.name.chars().count(), max);
+
+        let err = ops.save_new(new_thing("l2", &"x".repeat(max + 1), 1)).unwrap_err();
+        assert_eq!(err.error_code(), "VALIDATION_FAILED");
+        assert!(err.to_string().contains("'name'"), "{}", err);
+        assert!(ops.get("l2").unwrap().is_none());
+
+        let mut too_long = at_limit.clone();
+        too_long.name.push('x');
+        assert_eq!(ops.save(too_long).unwrap_err().error_code(), "VALIDATION_FAILED");
+        let patch = serde_json::json!({ "name": "x".repeat(max + 1) });
+        assert_eq!(ops.patch("l1", &patch).unwrap_err().error_code(), "VALIDATION_FAILED");
+        assert_eq!(ops.get_or_err("l1").unwrap().updated_at, at_limit.updated_at);
+    }
+
     #[test]
     fn patch_nonexistent_returns_not_found() {
         let (ops, _dir) = make_ops();
@@ -16,6 +16,7 @@ pub mod facet;
 pub mod format;
 pub mod hierarchy;
 pub mod kv;
+mod limits;
 pub mod sql;
 pub mod search;
 pub mod schema;
@@ -0,0 +1,29 @@
+//! Input size limits for stored records.
+//!
+//! Checked on the serialized record by both KvOps and SqlOps, before the
+//! write, so the limits hold for create, PUT and PATCH alike.
+
+use openerp_core::ServiceError;
+
+/// Longest value a top-level string field may hold, in characters.
+pub(crate) const MAX_FIELD_CHARS: usize = 10_000;
+
+/// Reject a record with a top-level string field over `MAX_FIELD_CHARS`.
+/// The error names the field (as serialized, so camelCase).
+pub(crate) fn check_field_lengths(val: &serde_json::Value) -> Result<(), ServiceError> {
+    let Some(obj) = val.as_object() else {
+        return Ok(());
+    };
+    for (field, value) in obj {
+        if let serde_json::Value::String(s) = value {
+            let len = s.chars().count();
+            if len > MAX_FIELD_CHARS {
+                return Err(ServiceError::Validation(format!(
+                    "field '{}' is {} characters long; the limit is {}",
+                    field, len, MAX_FIELD_CHARS
+                )));
+            }
+        }
+    }
+    Ok(())
+}
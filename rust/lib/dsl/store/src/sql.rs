@@ -79,8 +79,9 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
 
     fn sql_err(e: openerp_sql::SQLError) -> ServiceError {
         let msg = e.to_string();
+        // The driver message names tables and columns; don't pass it on.
         if msg.contains("UNIQUE") {
-            return ServiceError::Conflict(msg);
+            return ServiceError::Conflict(format!("{} already exists", T::resource()));
         }
         ServiceError::Storage(msg)
     }
@@ -146,6 +147,14 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
 
     /// Get a record by primary key.
     pub fn get(&self, pk: &[&str]) -> Result<Option<T>, ServiceError> {
+        Ok(self.get_with_data(pk)?.map(|(record, _)| record))
+    }
+
+    /// Get a record and its stored `data` column, for compare-and-swap.
+    fn get_with_data(
+        &self,
+        pk: &[&str],
+    ) -> Result<Option<(T, openerp_sql::Value)>, ServiceError> {
         let table = T::table_name();
         let pk_fields = T::PK;
         if pk.len() != pk_fields.len() {
@@ -169,15 +178,15 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
 
         let rows = self.sql.query(&sql, &params).map_err(Self::sql_err)?;
         if let Some(row) = rows.first() {
-            if let Some(openerp_sql::Value::Blob(data)) = row.get("data") {
-                let record: T = serde_json::from_slice(data)
+            if let Some(data @ openerp_sql::Value::Blob(bytes)) = row.get("data") {
+                let record: T = serde_json::from_slice(bytes)
                     .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
-                return Ok(Some(record));
+                return Ok(Some((record, data.clone())));
             }
-            if let Some(openerp_sql::Value::Text(data)) = row.get("data") {
-                let record: T = serde_json::from_str(data)
+            if let Some(data @ openerp_sql::Value::Text(text)) = row.get("data") {
+                let record: T = serde_json::from_str(text)
                     .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
-                return Ok(Some(record));
+                return Ok(Some((record, data.clone())));
             }
         }
         Ok(None)
@@ -197,29 +206,41 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
         Self::rows_to_records(&rows)
     }
 
-    /// List records with pagination (SQL LIMIT/OFFSET).
+    /// List records with pagination (SQL LIMIT/OFFSET), in PK order.
     ///
     /// Uses SQL-native pagination — only the requested page is fetched.
     /// Fetches limit+1 rows to determine `has_more` without a COUNT query.
+    /// `after` is a keyset cursor on a single-column PK, like the KV key;
+    /// it is rejected for compound PKs.
     pub fn list_paginated(
         &self,
         params: &openerp_core::ListParams,
     ) -> Result<openerp_core::ListResult<T>, ServiceError> {
         let fetch = params.limit + 1; // fetch one extra to detect has_more
+        let order: Vec<String> = T::PK.iter().map(|f| format!("\"{}\"", f.name)).collect();
+        let mut where_clause = String::new();
+        let mut query_params = Vec::new();
+        if let Some(after) = params.after.as_deref().filter(|a| !a.is_empty()) {
+            let [pk] = T::PK else {
+                return Err(ServiceError::Validation(
+                    "'after' is not supported for compound primary keys".into(),
+                ));
+            };
+            where_clause = format!(" WHERE \"{}\" > ?1", pk.name);
+            query_params.push(openerp_sql::Value::Text(after.to_string()));
+        }
+        let n = query_params.len();
         let sql = format!(
-            "SELECT data FROM \"{}\" LIMIT ?1 OFFSET ?2",
-            T::table_name()
+            "SELECT data FROM \"{}\"{} ORDER BY {} LIMIT ?{} OFFSET ?{}",
+            T::table_name(),
+            where_clause,
+            order.join(", "),
+            n + 1,
+            n + 2
         );
-        let rows = self
-            .sql
-            .query(
-                &sql,
-                &[
-                    openerp_sql::Value::Integer(fetch as i64),
-                    openerp_sql::Value::Integer(params.offset as i64),
-                ],
-            )
-            .map_err(Self::sql_err)?;
+        query_params.push(openerp_sql::Value::Integer(fetch as i64));
+        query_params.push(openerp_sql::Value::Integer(params.offset as i64));
+        let rows = self.sql.query(&sql, &query_params).map_err(Self::sql_err)?;
 
         let mut records = Self::rows_to_records(&rows)?;
         let has_more = records.len() > params.limit;
@@ -269,6 +290,7 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
 
         let mut json_val: serde_json::Value = serde_json::to_value(&record)
             .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
+        crate::limits::check_field_lengths(&json_val)?;
         crate::timestamp::stamp_create(&mut json_val);
         let record: T = serde_json::from_value(json_val.clone())
             .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
@@ -321,11 +343,17 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
     /// Compares the incoming record's `updatedAt` with the stored value.
     /// If they don't match, returns `ServiceError::Conflict` (409).
     /// The store layer sets a fresh `updatedAt`.
+    ///
+    /// The UPDATE only applies if the row still holds the data that was
+    /// checked, so of two concurrent writers exactly one wins, and a row
+    /// deleted in between is NotFound rather than silently not updated.
     pub fn save(&self, mut record: T) -> Result<T, ServiceError> {
         let pk_values = record.pk_values();
         let pk_refs: Vec<&str> = pk_values.iter().map(|s| s.as_str()).collect();
 
-        if let Some(existing) = self.get(&pk_refs)? {
+        let existing = self.get_with_data(&pk_refs)?;
+        let checked_data = existing.as_ref().map(|(_, data)| data.clone());
+        if let Some((existing, _)) = existing {
             let existing_json = serde_json::to_value(&existing)
                 .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
             let incoming_json = serde_json::to_value(&record)
@@ -347,24 +375,32 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
 
         let mut json_val = serde_json::to_value(&record)
             .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
+        crate::limits::check_field_lengths(&json_val)?;
         crate::timestamp::stamp_update(&mut json_val);
         let record: T = serde_json::from_value(json_val)
             .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
 
-        self.exec_update(&record)
+        self.exec_update(&record, checked_data)
     }
 
     /// Partially update a record using RFC 7386 JSON Merge Patch.
     ///
     /// Reads the existing record, applies the patch, and saves.
     /// Include `updatedAt` from the GET response for optimistic locking.
-    /// The store layer sets a fresh `updatedAt` after merge.
+    /// The store layer sets a fresh `updatedAt` after merge. Like `save`,
+    /// the write only lands on the row as it was read.
     pub fn patch(&self, pk: &[&str], patch: &serde_json::Value) -> Result<T, ServiceError> {
-        let existing = self.get_or_err(pk)?;
+        // A non-object merge patch replaces the whole record (RFC 7386).
+        if !patch.is_object() {
+            return Err(ServiceError::Validation("merge patch must be a JSON object".into()));
+        }
+        let (existing, checked_data) = self.get_with_data(pk)?.ok_or_else(|| {
+            ServiceError::NotFound(format!("{} not found", T::table_name()))
+        })?;
         let mut base = serde_json::to_value(&existing)
             .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
 
-        if let Some(patch_ts) = patch.get("updatedAt").and_then(|v| v.as_str()) {
+        if let Some(patch_ts) = crate::timestamp::patch_updated_at(patch)? {
             let base_ts = base.get("updatedAt").and_then(|v| v.as_str()).unwrap_or("");
             if patch_ts != base_ts {
                 return Err(ServiceError::Conflict(format!(
@@ -376,23 +412,33 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
 
         openerp_core::merge_patch(&mut base, patch);
 
+        // The patch is client input: a field of the wrong type is a 400.
         let mut record: T = serde_json::from_value(base)
-            .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
+            .map_err(|e| ServiceError::Validation(format!("invalid patch: {}", e)))?;
         record.before_update();
         Self::check_names(&record)?;
 
         let mut json_val = serde_json::to_value(&record)
             .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
+        crate::limits::check_field_lengths(&json_val)?;
         crate::timestamp::stamp_update(&mut json_val);
         let record: T = serde_json::from_value(json_val)
             .map_err(|e| ServiceError::Internal(format!("deserialize: {}", e)))?;
 
-        self.exec_update(&record)
+        self.exec_update(&record, Some(checked_data))
     }
 
     /// Execute an UPDATE statement for the given record.
     /// Shared by save() and patch() to avoid SQL building duplication.
-    fn exec_update(&self, record: &T) -> Result<T, ServiceError> {
+    ///
+    /// With `checked_data`, the row is only updated if its `data` column
+    /// still equals it (compare-and-swap). No row updated means the record
+    /// was deleted (NotFound) or changed by another writer (Conflict).
+    fn exec_update(
+        &self,
+        record: &T,
+        checked_data: Option<openerp_sql::Value>,
+    ) -> Result<T, ServiceError> {
         let data = serde_json::to_vec(record)
             .map_err(|e| ServiceError::Internal(format!("serialize: {}", e)))?;
 
@@ -432,7 +478,7 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
         params.push(openerp_sql::Value::Blob(data));
         idx += 1;
 
-        let where_clause: Vec<String> = pk_fields
+        let mut where_clause: Vec<String> = pk_fields
             .iter()
             .enumerate()
             .map(|(i, f)| {
@@ -440,6 +486,10 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
                 format!("\"{}\" = ?{}", f.name, idx + i)
             })
             .collect();
+        if let Some(data) = checked_data {
+            where_clause.push(format!("data = ?{}", idx + pk_fields.len()));
+            params.push(data);
+        }
 
         let sql = format!(
             "UPDATE \"{}\" SET {} WHERE {}",
@@ -448,7 +498,16 @@ impl<T: SqlStore + DslModel> SqlOps<T> {
             where_clause.join(" AND ")
         );
 
-        self.sql.exec(&sql, &params).map_err(Self::sql_err)?;
+        let updated = self.sql.exec(&sql, &params).map_err(Self::sql_err)?;
+        if updated == 0 {
+            let pk_refs: Vec<&str> = pk_values.iter().map(|s| s.as_str()).collect();
+            if self.get(&pk_refs)?.is_none() {
+                return Err(ServiceError::NotFound(format!("{} not found", T::table_name())));
+            }
+            return Err(ServiceError::Conflict(format!(
+                "{} was modified concurrently", T::resource()
+            )));
+        }
         Ok(record.clone())
     }
 
@@ -712,8 +771,8 @@ mod tests {
 
         let d2 = Device { sn: "DUP001".into(), model: 2, status: "b".into(), description: None };
         let err = ops.save_new(d2).unwrap_err();
-        assert!(err.to_string().contains("UNIQUE") || err.to_string().contains("already exists"),
-            "Duplicate PK should fail: {}", err);
+        assert_eq!(err.error_code(), "ALREADY_EXISTS", "Duplicate PK should fail: {}", err);
+        assert!(!err.to_string().contains("UNIQUE"), "driver message leaked: {}", err);
     }
 
     #[test]
@@ -815,6 +874,147 @@ mod tests {
         assert!(!result.has_more);
     }
 
+    #[test]
+    fn sql_list_paginated_after_cursor() {
+        let (ops, _dir) = make_ops();
+        // Inserted out of order: pages follow the PK, not insertion order.
+        for sn in ["K3", "K0", "K5", "K1", "K4", "K2"] {
+            let d = Device { sn: sn.into(), model: 1, status: "a".into(), description: None };
+            ops.save_new(d).unwrap();
+        }
+
+        let params = openerp_core::ListParams { limit: 3, ..Default::default() };
+        let page1 = ops.list_paginated(&params).unwrap();
+        let sns: Vec<&str> = page1.items.iter().map(|d| d.sn.as_str()).collect();
+        assert_eq!(sns, ["K0", "K1", "K2"]);
+        assert!(page1.has_more);
+
+        let params = openerp_core::ListParams {
+            limit: 3,
+            after: Some("K2".into()),
+            ..Default::default()
+        };
+        let page2 = ops.list_paginated(&params).unwrap();
+        let sns: Vec<&str> = page2.items.iter().map(|d| d.sn.as_str()).collect();
+        assert_eq!(sns, ["K3", "K4", "K5"]);
+        assert!(!page2.has_more);
+
+        let params = openerp_core::ListParams {
+            limit: 3,
+            after: Some("K5".into()),
+            ..Default::default()
+        };
+        assert!(ops.list_paginated(&params).unwrap().items.is_empty());
+    }
+
+    #[test]
+    fn sql_list_paginated_after_rejected_for_compound_pk() {
+        let dir = tempfile::tempdir().unwrap();
+        let sql: Arc<dyn openerp_sql::SQLStore> =
+            Arc::new(openerp_sql::SqliteStore::open(&dir.path().join("test3.db")).unwrap());
+        let ops = SqlOps::<Firmware>::new(sql);
+        ops.ensure_table().unwrap();
+
+        let params = openerp_core::ListParams {
+            limit: 10,
+            after: Some("100".into()),
+            ..Default::default()
+        };
+        let err = ops.list_paginated(&params).unwrap_err();
+        assert_eq!(err.error_code(), "VALIDATION_FAILED");
+    }
+
+    // Optimistic locking needs an `updatedAt` on the model.
+    #[derive(Debug, Clone, Serialize, Deserialize, PartialEq)]
+    #[serde(rename_all = "camelCase")]
+    struct Note {
+        id: String,
+        count: u32,
+        #[serde(default)]
+        updated_at: String,
+    }
+
+    impl SqlStore for Note {
+        const PK: &[Field] = &[Field::new("id", "String", "text")];
+
+        fn table_name() -> &'static str {
+            "notes"
+        }
+
+        fn pk_values(&self) -> Vec<String> {
+            vec![self.id.clone()]
+        }
+    }
+
+    impl DslModel for Note {
+        fn module() -> &'static str { "test" }
+        fn resource() -> &'static str { "note" }
+        fn resource_path() -> &'static str { "notes" }
+    }
+
+    fn make_note_ops() -> (Arc<SqlOps<Note>>, tempfile::TempDir) {
+        let dir = tempfile::tempdir().unwrap();
+        let sql: Arc<dyn openerp_sql::SQLStore> =
+            Arc::new(openerp_sql::SqliteStore::open(&dir.path().join("notes.db")).unwrap());
+        let ops = SqlOps::new(sql);
+        ops.ensure_table().unwrap();
+        (Arc::new(ops), dir)
+    }
+
+    #[test]
+    fn sql_concurrent_patches_with_same_updated_at_one_wins() {
+        let (ops, _dir) = make_note_ops();
+        let created = ops
+            .save_new(Note { id: "n1".into(), count: 0, updated_at: String::new() })
+            .unwrap();
+
+        let handles: Vec<_> = (0..20)
+            .map(|i| {
+                let ops = ops.clone();
+                let patch = serde_json::json!({ "count": i, "updatedAt": created.updated_at });
+                std::thread::spawn(move || ops.patch(&["n1"], &patch))
+            })
+            .collect();
+        let results: Vec<_> = handles.into_iter().map(|h| h.join().unwrap()).collect();
+
+        let winners: Vec<&Note> = results.iter().filter_map(|r| r.as_ref().ok()).collect();
+        assert_eq!(winners.len(), 1, "exactly one patch should win");
+        for err in results.iter().filter_map(|r| r.as_ref().err()) {
+            assert_eq!(err.error_code(), "ALREADY_EXISTS", "got: {}", err);
+        }
+        assert_eq!(ops.get_or_err(&["n1"]).unwrap().count, winners[0].count);
+    }
+
+    #[test]
+    fn sql_update_only_lands_on_the_row_it_read() {
+        let (ops, _dir) = make_note_ops();
+        ops.save_new(Note { id: "n3".into(), count: 0, updated_at: String::new() })
+            .unwrap();
+
+        // Read, then another writer lands before our UPDATE.
+        let (mut stale, stale_data) = ops.get_with_data(&["n3"]).unwrap().unwrap();
+        ops.patch(&["n3"], &serde_json::json!({ "count": 1 })).unwrap();
+        stale.count = 2;
+        let err = ops.exec_update(&stale, Some(stale_data)).unwrap_err();
+        assert_eq!(err.error_code(), "ALREADY_EXISTS", "{}", err);
+        assert_eq!(ops.get_or_err(&["n3"]).unwrap().count, 1);
+    }
+
+    #[test]
+    fn sql_writes_after_delete_are_not_found() {
+        let (ops, _dir) = make_note_ops();
+        let created = ops
+            .save_new(Note { id: "n2".into(), count: 0, updated_at: String::new() })
+            .unwrap();
+        ops.delete(&["n2"]).unwrap();
+
+        let err = ops.save(created).unwrap_err();
+        assert_eq!(err.error_code(), "NOT_FOUND", "{}", err);
+        let err = ops.patch(&["n2"], &serde_json::json!({ "count": 1 })).unwrap_err();
+        assert_eq!(err.error_code(), "NOT_FOUND", "{}", err);
+        assert!(ops.get(&["n2"]).unwrap().is_none());
+    }
+
     #[test]
     fn sql_count() {
         let (ops, _dir) = make_ops();
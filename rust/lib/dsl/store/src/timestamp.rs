@@ -4,10 +4,12 @@
 //! serialized JSON objects. Used by both KvOps and SqlOps so the logic
 //! lives in one place.
 
+use openerp_core::{now_rfc3339, ServiceError};
+
 /// Stamp `createdAt` (if empty) and `updatedAt` on a JSON object.
 pub(crate) fn stamp_create(val: &mut serde_json::Value) {
     if let Some(obj) = val.as_object_mut() {
-        let now = chrono::Utc::now().to_rfc3339();
+        let now = now_rfc3339();
         let ca = obj.get("createdAt").and_then(|v| v.as_str()).unwrap_or("");
         if ca.is_empty() {
             obj.insert("createdAt".into(), serde_json::json!(now));
@@ -19,9 +21,22 @@ pub(crate) fn stamp_create(val: &mut serde_json::Value) {
 /// Stamp a fresh `updatedAt` on a JSON object.
 pub(crate) fn stamp_update(val: &mut serde_json::Value) {
     if let Some(obj) = val.as_object_mut() {
-        obj.insert(
-            "updatedAt".into(),
-            serde_json::json!(chrono::Utc::now().to_rfc3339()),
-        );
+        obj.insert("updatedAt".into(), serde_json::json!(now_rfc3339()));
+    }
+}
+
+/// `updatedAt` of a merge patch, for the optimistic-lock check; `None` if
+/// the patch has none. Anything but an RFC 3339 string is a client error
+/// (400), not a lock mismatch (409).
+pub(crate) fn patch_updated_at(patch: &serde_json::Value) -> Result<Option<&str>, ServiceError> {
+    match patch.get("updatedAt") {
+        None => Ok(None),
+        Some(serde_json::Value::String(ts)) if chrono::DateTime::parse_from_rfc3339(ts).is_ok() => {
+            Ok(Some(ts.as_str()))
+        }
+        Some(v) => Err(ServiceError::Validation(format!(
+            "updatedAt must be an RFC 3339 timestamp, got {}",
+            v
+        ))),
     }
 }
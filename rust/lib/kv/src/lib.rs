@@ -8,4 +8,4 @@ pub use error::KVError;
 pub use file_loader::FileLoader;
 pub use overlay::OverlayKV;
 pub use redb::RedbStore;
-pub use traits::KVStore;
+pub use traits::{KVStore, Update};
@@ -2,7 +2,7 @@ use std::collections::{BTreeMap, BTreeSet};
 use std::sync::RwLock;
 
 use crate::error::KVError;
-use crate::traits::KVStore;
+use crate::traits::{KVStore, Update};
 
 /// OverlayKV is a two-layer KV store:
 ///
@@ -89,6 +89,23 @@ impl<DB: KVStore> KVStore for OverlayKV<DB> {
         self.db.batch_delete(keys)
     }
 
+    fn update(
+        &self,
+        key: &str,
+        f: &mut dyn FnMut(Option<&[u8]>) -> Update,
+    ) -> Result<(), KVError> {
+        // A file-layer key can be read but not changed. `f` still sees it,
+        // so the caller's own checks (e.g. for duplicates) come first.
+        let file_value = self.file_layer.read().unwrap().get(key).cloned();
+        if let Some(value) = file_value {
+            return match f(Some(&value)) {
+                Update::Keep => Ok(()),
+                _ => Err(KVError::ReadOnly(key.to_string())),
+            };
+        }
+        self.db.update(key, f)
+    }
+
     fn scan(&self, prefix: &str) -> Result<Vec<(String, Vec<u8>)>, KVError> {
         // Collect keys from both layers, file layer wins on conflict.
         let file_layer = self.file_layer.read().unwrap();
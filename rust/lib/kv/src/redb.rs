@@ -4,7 +4,7 @@ use std::sync::Arc;
 use redb::{Database, TableDefinition};
 
 use crate::error::KVError;
-use crate::traits::KVStore;
+use crate::traits::{KVStore, Update};
 
 const TABLE: TableDefinition<&str, &[u8]> = TableDefinition::new("kv");
 
@@ -135,6 +135,52 @@ impl KVStore for RedbStore {
         Ok(())
     }
 
+    fn update(
+        &self,
+        key: &str,
+        f: &mut dyn FnMut(Option<&[u8]>) -> Update,
+    ) -> Result<(), KVError> {
+        // redb allows one write transaction at a time, so the read below and
+        // the write `f` asks for cannot interleave with another writer.
+        let write_txn = self
+            .db
+            .begin_write()
+            .map_err(|e| KVError::Storage(e.to_string()))?;
+        let update = {
+            let mut table = write_txn
+                .open_table(TABLE)
+                .map_err(|e| KVError::Storage(e.to_string()))?;
+            let current = table
+                .get(key)
+                .map_err(|e| KVError::Storage(e.to_string()))?
+                .map(|val| val.value().to_vec());
+            let update = f(current.as_deref());
+            match &update {
+                Update::Keep => {}
+                Update::Set(value) => {
+                    table
+                        .insert(key, value.as_slice())
+                        .map_err(|e| KVError::Storage(e.to_string()))?;
+                }
+                Update::Delete => {
+                    table
+                        .remove(key)
+                        .map_err(|e| KVError::Storage(e.to_string()))?;
+                }
+            }
+            update
+        };
+        if update == Update::Keep {
+            return write_txn
+                .abort()
+                .map_err(|e| KVError::Storage(e.to_string()));
+        }
+        write_txn
+            .commit()
+            .map_err(|e| KVError::Storage(e.to_string()))?;
+        Ok(())
+    }
+
     fn scan(&self, prefix: &str) -> Result<Vec<(String, Vec<u8>)>, KVError> {
         let read_txn = self
             .db
@@ -27,6 +27,28 @@ pub trait KVStore: Send + Sync {
     /// Missing keys are silently ignored.
     fn batch_delete(&self, keys: &[&str]) -> Result<(), KVError>;
 
+    /// Atomically read-modify-write a key: `f` gets the current value (None
+    /// if missing) and decides what to store, within one transaction, so no
+    /// other write can land in between. Keep `f` short — writers wait on it.
+    /// Returns KVError::ReadOnly if the key is in the read-only layer and
+    /// `f` asks to change it.
+    fn update(
+        &self,
+        key: &str,
+        f: &mut dyn FnMut(Option<&[u8]>) -> Update,
+    ) -> Result<(), KVError>;
+
     /// Check whether a key is in the read-only (file) layer.
     fn is_readonly(&self, key: &str) -> bool;
 }
+
+/// What `KVStore::update` does with the key once its closure has run.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum Update {
+    /// Leave the key as it is; nothing is written.
+    Keep,
+    /// Store this value.
+    Set(Vec<u8>),
+    /// Remove the key (a no-op if it is missing).
+    Delete,
+}
@@ -12,6 +12,7 @@ use openerp_core::{Authenticator, ServiceError};
 pub struct AuthChecker {
     jwt_secret: String,
     root_role: String,
+    leeway_secs: u64,
     kv: Arc<dyn openerp_kv::KVStore>,
 }
 
@@ -24,10 +25,17 @@ impl AuthChecker {
         Self {
             jwt_secret: jwt_secret.to_string(),
             root_role: root_role.to_string(),
+            leeway_secs: jsonwebtoken::Validation::default().leeway,
             kv,
         }
     }
 
+    /// Seconds a token is still accepted past its `exp` (default 60).
+    pub fn with_leeway(mut self, leeway_secs: u64) -> Self {
+        self.leeway_secs = leeway_secs;
+        self
+    }
+
     fn extract_roles(&self, headers: &HeaderMap) -> Result<Vec<String>, ServiceError> {
         // Get JWT from Authorization: Bearer <token>
         let token = headers
@@ -38,7 +46,8 @@ impl AuthChecker {
 
         // Decode JWT.
         let key = jsonwebtoken::DecodingKey::from_secret(self.jwt_secret.as_bytes());
-        let validation = jsonwebtoken::Validation::default();
+        let mut validation = jsonwebtoken::Validation::default();
+        validation.leeway = self.leeway_secs;
         let data = jsonwebtoken::decode::<Claims>(token, &key, &validation)
             .map_err(|e| ServiceError::Unauthorized(format!("invalid token: {}", e)))?;
 
@@ -207,6 +207,59 @@ mod tests {
         assert!(!created["id"].as_str().unwrap().is_empty());
     }
 
+    #[tokio::test]
+    async fn admin_router_refuses_root_user_id() {
+        use axum::body::Body;
+        use axum::http::{Request, StatusCode};
+        use tower::ServiceExt;
+
+        let dir = tempfile::tempdir().unwrap();
+        let kv: Arc<dyn openerp_kv::KVStore> = Arc::new(
+            openerp_kv::RedbStore::open(&dir.path().join("root.redb")).unwrap(),
+        );
+        let auth: Arc<dyn openerp_core::Authenticator> = Arc::new(openerp_core::AllowAll);
+        let router = admin_router(kv, auth);
+
+        let send = |method: &str, uri: String, body: serde_json::Value| {
+            let req = Request::builder()
+                .method(method)
+                .uri(uri)
+                .header("content-type", "application/json")
+                .body(Body::from(body.to_string()))
+                .unwrap();
+            let router = router.clone();
+            async move {
+                let resp = router.oneshot(req).await.unwrap();
+                let status = resp.status();
+                let body = axum::body::to_bytes(resp.into_body(), 1024 * 1024).await.unwrap();
+                (status, serde_json::from_slice::<serde_json::Value>(&body).unwrap_or_default())
+            }
+        };
+
+        let (status, alice) = send("POST", "/users".into(), serde_json::json!({
+            "email": "alice@test.com", "active": true,
+        })).await;
+        assert_eq!(status, StatusCode::OK);
+        let alice_id = alice["id"].as_str().unwrap();
+
+        let attempts = [
+            ("create", "POST", "/users".to_string(), serde_json::json!({"id": "root", "active": true})),
+            ("update", "PUT", "/users/root".to_string(), serde_json::json!({"id": "root", "active": true})),
+            ("patch", "PATCH", "/users/root".to_string(), serde_json::json!({"active": false})),
+            ("rename", "PATCH", format!("/users/{alice_id}"), serde_json::json!({"id": "root"})),
+            ("delete", "DELETE", "/users/root".to_string(), serde_json::Value::Null),
+        ];
+        for (what, method, uri, body) in attempts {
+            let (status, err) = send(method, uri, body).await;
+            assert_eq!(status, StatusCode::FORBIDDEN, "{what}");
+            assert_eq!(err["code"], "PERMISSION_DENIED", "{what}");
+        }
+
+        // Nothing was stored under the root ID.
+        let (status, _) = send("GET", "/users/root".into(), serde_json::Value::Null).await;
+        assert_eq!(status, StatusCode::NOT_FOUND);
+    }
+
     // ── Password tests ──
 
     #[test]
@@ -758,6 +811,36 @@ mod tests {
             "Error should mention invalid/expired, got: {}", err_msg);
     }
 
+    #[test]
+    fn auth_checker_leeway() {
+        let dir = tempfile::tempdir().unwrap();
+        let kv: Arc<dyn openerp_kv::KVStore> = Arc::new(
+            openerp_kv::RedbStore::open(&dir.path().join("ac_leeway.redb")).unwrap(),
+        );
+
+        // Expired 10s ago: inside the default 60s leeway, outside a zero one.
+        let claims = serde_json::json!({
+            "sub": "root", "roles": ["auth:root"],
+            "iat": chrono::Utc::now().timestamp() - 20,
+            "exp": chrono::Utc::now().timestamp() - 10,
+        });
+        let token = jsonwebtoken::encode(
+            &jsonwebtoken::Header::default(),
+            &claims,
+            &jsonwebtoken::EncodingKey::from_secret(b"test-secret"),
+        ).unwrap();
+        let mut headers = axum::http::HeaderMap::new();
+        headers.insert("authorization", format!("Bearer {}", token).parse().unwrap());
+
+        let lenient = handlers::policy_check::AuthChecker::new(kv.clone(), "test-secret", "auth:root");
+        assert!(lenient.check(&headers, "anything").is_ok());
+
+        let strict = handlers::policy_check::AuthChecker::new(kv, "test-secret", "auth:root")
+            .with_leeway(0);
+        let err = strict.check(&headers, "anything").unwrap_err().to_string();
+        assert!(err.contains("invalid token"), "got: {}", err);
+    }
+
     // ── Integration: admin PUT update ──
 
     #[tokio::test]
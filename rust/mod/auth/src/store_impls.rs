@@ -80,6 +80,11 @@ pub fn find_roles_for_user(
 
 // ── User ──
 
+/// ID (and login name) of the built-in superadmin. It lives in the server
+/// config, not the store: no stored user may take its ID, and it can never
+/// be deleted.
+pub const ROOT_USER_ID: &str = "root";
+
 impl KvStore for User {
     const KEY: Field = Self::id;
     fn kv_prefix() -> &'static str { "auth:user:" }
@@ -89,6 +94,22 @@ impl KvStore for User {
             self.id = Id::new(uuid::Uuid::new_v4().to_string().replace('-', ""));
         }
     }
+    fn check_delete(id: &str) -> Result<(), openerp_core::ServiceError> {
+        if id == ROOT_USER_ID {
+            return Err(openerp_core::ServiceError::PermissionDenied(
+                "the root user cannot be deleted".into(),
+            ));
+        }
+        Ok(())
+    }
+    fn check_write(id: &str) -> Result<(), openerp_core::ServiceError> {
+        if id == ROOT_USER_ID {
+            return Err(openerp_core::ServiceError::PermissionDenied(
+                "the root user lives in the server config and cannot be stored".into(),
+            ));
+        }
+        Ok(())
+    }
 }
 
 // ── Role ──
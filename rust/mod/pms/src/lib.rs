@@ -489,4 +489,77 @@ mod tests {
         assert_eq!(fw["semver"], "2.0.0");
         assert!(!fw["id"].as_str().unwrap().is_empty());
     }
+
+    // ── MFG facet: field subset ──
+
+    #[tokio::test]
+    async fn mfg_facet_projects_device_field_subset() {
+        use axum::body::Body;
+        use axum::http::{Request, StatusCode};
+        use tower::ServiceExt;
+
+        let dir = tempfile::tempdir().unwrap();
+        let kv: Arc<dyn openerp_kv::KVStore> = Arc::new(
+            openerp_kv::RedbStore::open(&dir.path().join("mfg.redb")).unwrap(),
+        );
+        let auth: Arc<dyn openerp_core::Authenticator> = Arc::new(openerp_core::AllowAll);
+        let admin = admin_router(kv.clone(), auth);
+        let mfg = handlers::mfg::router(kv);
+
+        async fn get(router: &Router, uri: &str) -> (StatusCode, serde_json::Value) {
+            let req = Request::builder().uri(uri).body(Body::empty()).unwrap();
+            let resp = router.clone().oneshot(req).await.unwrap();
+            let status = resp.status();
+            let body = axum::body::to_bytes(resp.into_body(), 1024 * 1024).await.unwrap();
+            (status, serde_json::from_slice(&body).unwrap())
+        }
+
+        for (uri, body) in [
+            ("/devices", serde_json::json!({
+                "sn": "E2E-SN-001", "secret": "s3cret", "model": 42, "status": "ACTIVE",
+                "sku": "TEST-SKU", "imei": ["860000001"], "displayName": "E2E Test Device",
+            })),
+            ("/models", serde_json::json!({
+                "code": 42, "seriesName": "E2E-Series", "displayName": "E2E Model",
+            })),
+        ] {
+            let req = Request::builder()
+                .method("POST").uri(uri)
+                .header("content-type", "application/json")
+                .body(Body::from(serde_json::to_string(&body).unwrap())).unwrap();
+            let resp = admin.clone().oneshot(req).await.unwrap();
+            assert_eq!(resp.status(), StatusCode::OK, "POST {}", uri);
+        }
+
+        // List: the projected fields, and nothing secret.
+        let (status, list) = get(&mfg, "/devices").await;
+        assert_eq!(status, StatusCode::OK);
+        let items = list["items"].as_array().unwrap();
+        let device = items.iter().find(|d| d["sn"] == "E2E-SN-001").expect("device in MFG facet");
+        assert_eq!(device["model"], 42);
+        assert_eq!(device["status"], "ACTIVE");
+        assert_eq!(device["sku"], "TEST-SKU");
+        assert_eq!(device["imei"], serde_json::json!(["860000001"]));
+        assert_eq!(device["displayName"], "E2E Test Device");
+        assert!(device.get("secret").is_none(), "secret not exposed in MFG facet");
+        assert!(device.get("passwordHash").is_none());
+
+        // Single device.
+        let (status, facet) = get(&mfg, "/devices/E2E-SN-001").await;
+        assert_eq!(status, StatusCode::OK);
+        assert_eq!(facet["sn"], "E2E-SN-001");
+        assert!(facet.get("secret").is_none(), "secret not exposed");
+
+        // Models.
+        let (status, models) = get(&mfg, "/models").await;
+        assert_eq!(status, StatusCode::OK);
+        let model = models["items"].as_array().unwrap().iter().find(|m| m["code"] == 42);
+        assert_eq!(model.expect("model in MFG facet")["seriesName"], "E2E-Series");
+
+        // The admin API returns more fields than the facet.
+        let (status, full) = get(&admin, "/devices/E2E-SN-001").await;
+        assert_eq!(status, StatusCode::OK);
+        let (admin_keys, facet_keys) = (full.as_object().unwrap().len(), facet.as_object().unwrap().len());
+        assert!(admin_keys > facet_keys, "admin has {} fields, facet {}", admin_keys, facet_keys);
+    }
 }